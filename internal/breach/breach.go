@@ -3,7 +3,6 @@ package breach
 import (
 	"fmt"
 	"math/rand"
-	"time"
 )
 
 type NodeType int
@@ -48,11 +47,14 @@ type Game struct {
 	Win        bool
 	GameOver   bool
 	Log        []string
+	Seed       int64
+	rng        *rand.Rand
 }
 
-func NewGame() *Game {
-	rand.Seed(time.Now().UnixNano())
+func NewGame(seed int64) *Game {
 	g := &Game{
+		Seed:        seed,
+		rng:         rand.New(rand.NewSource(seed)),
 		CurrentNode: 0,
 		Trace:       0,
 		CPU:         10,
@@ -76,7 +78,7 @@ func (g *Game) generateNetwork() {
 		if i == 7 { ntype = Database; name = "DB-SERVER" }
 		if i == 8 { ntype = Core; name = "MAIN-CORE" }
 
-		maxSec := 20 + rand.Intn(30)
+		maxSec := 20 + g.rng.Intn(30)
 		if ntype == Firewall { maxSec = 60 }
 		if ntype == Core { maxSec = 100 }
 
@@ -130,7 +132,7 @@ func (g *Game) RunProgram(p Program) {
 	node := g.Nodes[g.CurrentNode]
 	switch p {
 	case Crack:
-		damage := 5 + rand.Intn(10)
+		damage := 5 + g.rng.Intn(10)
 		node.Security -= damage
 		g.Trace += 1.5
 		g.AddLog(fmt.Sprintf("CRACK.EXE: Deployed. Sec-layer reduced by %d.", damage))
@@ -144,7 +146,7 @@ func (g *Game) RunProgram(p Program) {
 			}
 		}
 	case Stealth:
-		reduction := 2 + rand.Intn(5)
+		reduction := 2 + g.rng.Intn(5)
 		g.Trace -= float64(reduction)
 		if g.Trace < 0 { g.Trace = 0 }
 		g.AddLog(fmt.Sprintf("STEALTH.SH: Rerouting packets. Trace reduced by %d%%.", reduction))
@@ -7,6 +7,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"atlas.games/internal/registry"
 )
 
 var (
@@ -25,9 +26,20 @@ type Model struct {
 	game *Game
 }
 
-func NewModel() Model {
+func init() {
+	registry.Register(registry.Game{
+		ID:          "breach",
+		Title:       "Atlas Breach",
+		Description: "Cyber-hacking sim. Crack the network core before the trace completes.",
+		Category:    registry.Arcade,
+		Order:       2,
+		New:         func(seed int64) tea.Model { return NewModel(seed) },
+	})
+}
+
+func NewModel(seed int64) Model {
 	return Model{
-		game: NewGame(),
+		game: NewGame(seed),
 	}
 }
 
@@ -48,7 +60,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			targetID := int(msg.String()[0] - '1')
 			m.game.Move(targetID)
 		case "r":
-			m.game = NewGame()
+			m.game = NewGame(time.Now().UnixNano())
 		}
 	case tickMsg:
 		m.game.Tick()
@@ -107,9 +119,9 @@ func (m Model) View() string {
 		m.game.Trace)
 	
 	statusInfo := fmt.Sprintf(
-		"LOCATION:  %s\nSECURITY:  %d%%\nSTATUS:    %s\n\n%s\n%s\n",
+		"LOCATION:  %s\nSECURITY:  %d%%\nSTATUS:    %s\nSEED:      %d\n\n%s\n%s\n",
 		currentNode.Name, currentNode.Security, 
-		m.getStatusText(currentNode),
+		m.getStatusText(currentNode), m.game.Seed,
 		traceStyle.Render("TRACE DETECTION:"), traceStyle.Render(traceBar),
 	)
 
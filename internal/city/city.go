@@ -1,8 +1,8 @@
 package city
 
 import (
-	"math/rand"
-	"time"
+	"atlas.games/internal/mapio"
+	"atlas.games/internal/solver"
 )
 
 type TileType int
@@ -24,204 +24,359 @@ const (
 	Park
 	Commercial
 	Water
+	Factory
+	// A tower is 2x2 and a mall 3x2; their parts only fit together in
+	// place, see footprintSockets.
+	TowerTL
+	TowerTR
+	TowerBL
+	TowerBR
+	MallTL
+	MallT
+	MallTR
+	MallBL
+	MallB
+	MallBR
+	// Bridges carry a road over water; quays wall off one side of a block
+	// from the water and shores round off two.
+	BridgeV
+	BridgeH
+	QuayN
+	QuayE
+	QuayS
+	QuayW
+	ShoreNE
+	ShoreSE
+	ShoreSW
+	ShoreNW
 )
 
-// Sockets: [Top, Right, Bottom, Left]
-// 0: No connection, 1: Road connection
+// Hex roads, used on solver.Hex grids. Each connects the directions in its
+// mask (bit d is solver hex direction d); tiles from hexRoadBase on follow
+// the order of hexRoadMasks.
+const hexRoadBase = ShoreNW + 1
+
+// hexRoadMasks are straights, wide bends, forks and the six-way star.
+// Sharp bends are left out since they read poorly as text.
+var hexRoadMasks = []uint8{
+	1<<solver.HexE | 1<<solver.HexW,
+	1<<solver.HexNE | 1<<solver.HexSW,
+	1<<solver.HexNW | 1<<solver.HexSE,
+	1<<solver.HexNE | 1<<solver.HexSE,
+	1<<solver.HexE | 1<<solver.HexSW,
+	1<<solver.HexSE | 1<<solver.HexW,
+	1<<solver.HexSW | 1<<solver.HexNW,
+	1<<solver.HexW | 1<<solver.HexNE,
+	1<<solver.HexNW | 1<<solver.HexE,
+	1<<solver.HexNE | 1<<solver.HexSE | 1<<solver.HexW,
+	1<<solver.HexE | 1<<solver.HexSW | 1<<solver.HexNW,
+	0x3f,
+}
+
+// hexRoadWeights follow the square roads: straights are common, forks
+// rare and the star rarest.
+var hexRoadWeights = []int{10, 10, 10, 5, 5, 5, 5, 5, 5, 3, 3, 2}
+
+// hexCross is the star, which seeds hex cities.
+var hexCross = hexRoadBase + TileType(len(hexRoadMasks)-1)
+
+// Water sockets, see socketFits. wet is open water, which meets water,
+// banks and blank sides; bank is the water side of a bridge or quay, which
+// only meets water.
+const (
+	wet  = -1
+	bank = -2
+)
+
+// Sockets: [Top, Right, Bottom, Left], indexed by solver direction
+// 0: No connection, 1: Road connection, 2 and up: the inside of a
+// footprint, each shared by the two parts on either side of it; wet and
+// bank: water
 var sockets = map[TileType][4]int{
-	RoadV:     {1, 0, 1, 0},
-	RoadH:     {0, 1, 0, 1},
-	RoadTL:    {0, 1, 1, 0},
-	RoadTR:    {0, 0, 1, 1},
-	RoadBL:    {1, 1, 0, 0},
-	RoadBR:    {1, 0, 0, 1},
-	RoadTU:    {1, 1, 0, 1},
-	RoadTD:    {0, 1, 1, 1},
-	RoadTLT:   {1, 1, 1, 0},
-	RoadTRT:   {1, 0, 1, 1},
-	RoadCross: {1, 1, 1, 1},
-	Building:  {0, 0, 0, 0},
-	Park:      {0, 0, 0, 0},
+	RoadV:      {1, 0, 1, 0},
+	RoadH:      {0, 1, 0, 1},
+	RoadTL:     {0, 1, 1, 0},
+	RoadTR:     {0, 0, 1, 1},
+	RoadBL:     {1, 1, 0, 0},
+	RoadBR:     {1, 0, 0, 1},
+	RoadTU:     {1, 1, 0, 1},
+	RoadTD:     {0, 1, 1, 1},
+	RoadTLT:    {1, 1, 1, 0},
+	RoadTRT:    {1, 0, 1, 1},
+	RoadCross:  {1, 1, 1, 1},
+	Building:   {0, 0, 0, 0},
+	Park:       {0, 0, 0, 0},
 	Commercial: {0, 0, 0, 0},
-	Water:     {0, 0, 0, 0},
+	Water:      {wet, wet, wet, wet},
+	Factory:    {0, 0, 0, 0},
+	TowerTL:    {0, 2, 3, 0},
+	TowerTR:    {0, 0, 4, 2},
+	TowerBL:    {3, 5, 0, 0},
+	TowerBR:    {4, 0, 0, 5},
+	MallTL:     {0, 6, 7, 0},
+	MallT:      {0, 8, 9, 6},
+	MallTR:     {0, 0, 10, 8},
+	MallBL:     {7, 11, 0, 0},
+	MallB:      {9, 12, 0, 11},
+	MallBR:     {10, 0, 0, 12},
+	BridgeV:    {1, bank, 1, bank},
+	BridgeH:    {bank, 1, bank, 1},
+	QuayN:      {bank, 0, 0, 0},
+	QuayE:      {0, bank, 0, 0},
+	QuayS:      {0, 0, bank, 0},
+	QuayW:      {0, 0, 0, bank},
+	ShoreNE:    {bank, bank, 0, 0},
+	ShoreSE:    {0, bank, bank, 0},
+	ShoreSW:    {0, 0, bank, bank},
+	ShoreNW:    {bank, 0, 0, bank},
+}
+
+// footprints are the multi-cell buildings, each listed as its parts.
+var footprints = [][]TileType{
+	{TowerTL, TowerTR, TowerBL, TowerBR},
+	{MallTL, MallT, MallTR, MallBL, MallB, MallBR},
+}
+
+// isPart reports whether t is part of a multi-cell building.
+func isPart(t TileType) bool {
+	return t >= TowerTL && t <= MallBR
 }
 
 var weights = map[TileType]int{
-	RoadV:     10, RoadH: 10,
-	RoadTL:    5, RoadTR: 5, RoadBL: 5, RoadBR: 5,
-	RoadTU:    3, RoadTD: 3, RoadTLT: 3, RoadTRT: 3,
-	RoadCross: 2,
-	Building:  40,
-	Park:      15,
+	RoadV:      10, RoadH: 10,
+	RoadTL:     5, RoadTR: 5, RoadBL: 5, RoadBR: 5,
+	RoadTU:     3, RoadTD: 3, RoadTLT: 3, RoadTRT: 3,
+	RoadCross:  2,
+	Building:   40,
+	Park:       15,
 	Commercial: 20,
-	Water:     10,
+	Water:      10,
+	Factory:    8,
+	TowerTL:    3, TowerTR: 3, TowerBL: 3, TowerBR: 3,
+	MallTL:     2, MallT: 2, MallTR: 2, MallBL: 2, MallB: 2, MallBR: 2,
+	BridgeV:    3, BridgeH: 3,
+	QuayN:      2, QuayE: 2, QuayS: 2, QuayW: 2,
+	ShoreNE:    1, ShoreSE: 1, ShoreSW: 1, ShoreNW: 1,
 }
 
-type Tile struct {
-	Type          TileType
-	Possibilities []TileType
-	Collapsed     bool
+// tileInfo is the shared palette for the TUI and the exporters.
+var tileInfo = map[TileType]mapio.Cell{
+	RoadV:      {Name: "road_v", Glyph: "║", Color: "244"},
+	RoadH:      {Name: "road_h", Glyph: "═", Color: "244"},
+	RoadTL:     {Name: "road_tl", Glyph: "╔", Color: "244"},
+	RoadTR:     {Name: "road_tr", Glyph: "╗", Color: "244"},
+	RoadBL:     {Name: "road_bl", Glyph: "╚", Color: "244"},
+	RoadBR:     {Name: "road_br", Glyph: "╝", Color: "244"},
+	RoadTU:     {Name: "road_tu", Glyph: "╩", Color: "244"},
+	RoadTD:     {Name: "road_td", Glyph: "╦", Color: "244"},
+	RoadTLT:    {Name: "road_tlt", Glyph: "╠", Color: "244"},
+	RoadTRT:    {Name: "road_trt", Glyph: "╣", Color: "244"},
+	RoadCross:  {Name: "road_cross", Glyph: "╬", Color: "244"},
+	Building:   {Name: "building", Glyph: "█", Color: "255"},
+	Park:       {Name: "park", Glyph: "♣", Color: "34"},
+	Commercial: {Name: "commercial", Glyph: "S", Color: "220"},
+	Water:      {Name: "water", Glyph: "~", Color: "33"},
+	Factory:    {Name: "factory", Glyph: "▓", Color: "130"},
+	TowerTL:    {Name: "tower_tl", Glyph: "▛", Color: "252"},
+	TowerTR:    {Name: "tower_tr", Glyph: "▜", Color: "252"},
+	TowerBL:    {Name: "tower_bl", Glyph: "▙", Color: "252"},
+	TowerBR:    {Name: "tower_br", Glyph: "▟", Color: "252"},
+	MallTL:     {Name: "mall_tl", Glyph: "┏", Color: "220"},
+	MallT:      {Name: "mall_t", Glyph: "━", Color: "220"},
+	MallTR:     {Name: "mall_tr", Glyph: "┓", Color: "220"},
+	MallBL:     {Name: "mall_bl", Glyph: "┗", Color: "220"},
+	MallB:      {Name: "mall_b", Glyph: "━", Color: "220"},
+	MallBR:     {Name: "mall_br", Glyph: "┛", Color: "220"},
+	BridgeV:    {Name: "bridge_v", Glyph: "╫", Color: "137"},
+	BridgeH:    {Name: "bridge_h", Glyph: "╪", Color: "137"},
+	QuayN:      {Name: "quay_n", Glyph: "▔", Color: "250"},
+	QuayE:      {Name: "quay_e", Glyph: "▕", Color: "250"},
+	QuayS:      {Name: "quay_s", Glyph: "▁", Color: "250"},
+	QuayW:      {Name: "quay_w", Glyph: "▏", Color: "250"},
+	ShoreNE:    {Name: "shore_ne", Glyph: "◥", Color: "222"},
+	ShoreSE:    {Name: "shore_se", Glyph: "◢", Color: "222"},
+	ShoreSW:    {Name: "shore_sw", Glyph: "◣", Color: "222"},
+	ShoreNW:    {Name: "shore_nw", Glyph: "◤", Color: "222"},
 }
 
-type WFC struct {
-	Width  int
-	Height int
-	Grid   [][]Tile
+var hexDirNames = [6]string{"ne", "e", "se", "sw", "w", "nw"}
+
+func init() {
+	for i, mask := range hexRoadMasks {
+		t := hexRoadBase + TileType(i)
+		name := "hex_road"
+		for d, n := range hexDirNames {
+			if mask&(1<<d) != 0 {
+				name += "_" + n
+			}
+		}
+		tileInfo[t] = mapio.Cell{Name: name, Glyph: hexRoadGlyph(mask), Color: "244"}
+		weights[t] = hexRoadWeights[i]
+		hexTypes = append(hexTypes, t)
+	}
 }
 
-func NewWFC(width, height int) *WFC {
-	rand.Seed(time.Now().UnixNano())
-	w := &WFC{
-		Width:  width,
-		Height: height,
-		Grid:   make([][]Tile, height),
+// hexRoadGlyph draws a hex road in the two columns a hex cell takes: the
+// left column shows the exits to the west, the right one those to the east.
+func hexRoadGlyph(mask uint8) string {
+	side := func(up, across, down int) string {
+		switch bits := [3]bool{mask&(1<<up) != 0, mask&(1<<across) != 0, mask&(1<<down) != 0}; bits {
+		case [3]bool{false, false, false}:
+			return " "
+		case [3]bool{false, true, false}:
+			return "═"
+		case [3]bool{true, false, true}:
+			if up == solver.HexNW {
+				return ">"
+			}
+			return "<"
+		case [3]bool{true, false, false}:
+			if up == solver.HexNW {
+				return "╲"
+			}
+			return "╱"
+		case [3]bool{false, false, true}:
+			if up == solver.HexNW {
+				return "╱"
+			}
+			return "╲"
+		}
+		return "╬"
 	}
+	return side(solver.HexNW, solver.HexW, solver.HexSW) + side(solver.HexNE, solver.HexE, solver.HexSE)
+}
 
-	allTypes := []TileType{
-		RoadV, RoadH, RoadTL, RoadTR, RoadBL, RoadBR,
-		RoadTU, RoadTD, RoadTLT, RoadTRT, RoadCross,
-		Building, Park, Commercial, Water,
+func (t TileType) String() string {
+	if info, ok := tileInfo[t]; ok {
+		return info.Name
 	}
+	return "empty"
+}
 
-	for y := 0; y < height; y++ {
-		w.Grid[y] = make([]Tile, 0, width)
-		for x := 0; x < width; x++ {
-			w.Grid[y] = append(w.Grid[y], Tile{
-				Possibilities: append([]TileType{}, allTypes...),
-				Collapsed:     false,
-			})
-		}
+func (t TileType) Glyph() string {
+	if info, ok := tileInfo[t]; ok {
+		return info.Glyph
 	}
+	return " "
+}
 
-	// Seed some starting points
-	w.collapseTile(width/2, height/2, RoadCross)
-	w.collapseTile(rand.Intn(width), rand.Intn(height), Water)
+// Tile is one grid cell.
+type Tile = solver.Tile[TileType]
 
-	return w
+// WFC is the city generator: socket matching running on the shared solver,
+// followed by the network pass in network.go.
+type WFC struct {
+	*solver.Solver[TileType]
+	Exits       []int // Edges, as solver directions, that need a road out
+	Network     Network
+	Regenerated int      // Cities started over because an exit was cut off
+	Zones       [][]Zone // Districts planned while seeding, see zoning.go
 }
 
-func (w *WFC) collapseTile(x, y int, t TileType) {
-	tile := &w.Grid[y][x]
-	if tile.Collapsed { return }
-	tile.Type = t
-	tile.Collapsed = true
-	tile.Possibilities = []TileType{t}
-	w.Propagate(x, y)
+var allTypes = []TileType{
+	RoadV, RoadH, RoadTL, RoadTR, RoadBL, RoadBR,
+	RoadTU, RoadTD, RoadTLT, RoadTRT, RoadCross,
+	Building, Park, Commercial, Water, Factory,
+	TowerTL, TowerTR, TowerBL, TowerBR,
+	MallTL, MallT, MallTR, MallBL, MallB, MallBR,
+	BridgeV, BridgeH, QuayN, QuayE, QuayS, QuayW,
+	ShoreNE, ShoreSE, ShoreSW, ShoreNW,
 }
 
-func (w *WFC) GetEntropy(x, y int) int {
-	if w.Grid[y][x].Collapsed { return 999 }
-	return len(w.Grid[y][x].Possibilities)
-}
+// hexTypes are the tiles of a hex city: the single-cell blocks of allTypes
+// with hex roads instead of square ones. Footprints, bridges and quays need
+// square cells. The roads are added by init.
+var hexTypes = []TileType{Building, Park, Commercial, Water, Factory}
 
-func (w *WFC) Collapse() bool {
-	minEntropy := 999
-	var candidates [][2]int
+// NewWFC prepares a width x height city. A nil topology selects
+// solver.Square; solver.Hex swaps the roads for six-socket hex roads.
+// Sockets sit on cell edges, so solver.Moore is treated as Square.
+func NewWFC(width, height int, seed int64, topo solver.Topology) *WFC {
+	return NewConnectedWFC(width, height, seed, topo, nil)
+}
 
-	for y := 0; y < w.Height; y++ {
-		for x := 0; x < w.Width; x++ {
-			e := w.GetEntropy(x, y)
-			if e < minEntropy {
-				minEntropy = e
-				candidates = [][2]int{{x, y}}
-			} else if e == minEntropy {
-				candidates = append(candidates, [2]int{x, y})
-			}
-		}
+// NewConnectedWFC is NewWFC with a road leaving through the middle of each
+// edge in exits (solver.Up, Right, Down or Left). Wrapped cities have no
+// edges and ignore them.
+func NewConnectedWFC(width, height int, seed int64, topo solver.Topology, exits []int) *WFC {
+	if m, ok := topo.(solver.Moore); ok {
+		topo = solver.Square{Wrap: m.Wrap}
 	}
+	tiles, match := allTypes, socketsMatch
+	if _, ok := topo.(solver.Hex); ok {
+		tiles, match = hexTypes, hexSocketsMatch
+	}
+	w := &WFC{Exits: exits}
+	w.Solver = solver.New(width, height, seed, topo, solver.Rules[TileType]{
+		Tiles:  tiles,
+		Weight: func(t TileType) int { return weights[t] },
+		Allows: match,
+		Bias:   w.bias,
+		Seed: func(s *solver.Solver[TileType]) {
+			// The first seeding runs inside solver.New, before it returns
+			w.Solver = s
+			w.Network = Network{}
+			wx, wy := w.plan(s)
+			w.pinExits(s)
+			seedCity(s, wx, wy)
+			w.closeEdges(s)
+		},
+	})
+	return w
+}
 
-	if minEntropy == 999 { return true }
-
-	c := candidates[rand.Intn(len(candidates))]
-	x, y := c[0], c[1]
+// Step advances the city by one collapse, running Connect once the grid is
+// full. It returns true when the city is done.
+func (w *WFC) Step() bool {
+	return w.Solver.Step() && w.Connect()
+}
 
-	tile := &w.Grid[y][x]
-	if len(tile.Possibilities) == 0 { return false }
+// socketsMatch lets b sit in direction dir of a when the facing sockets
+// fit.
+func socketsMatch(a, b TileType, dir int) bool {
+	return socketFits(sockets[a][dir], sockets[b][(dir+2)%4])
+}
 
-	typeWeights := make(map[TileType]int)
-	for _, p := range tile.Possibilities {
-		typeWeights[p] = weights[p]
+// socketFits reports whether sockets p and q may face each other: road
+// meets road, blank meets blank and each footprint inside its other half.
+// Open water also meets blank sides, so blocks can line a lake, but the
+// water side of a bridge or quay needs water, and roads never run into it.
+func socketFits(p, q int) bool {
+	if p > q {
+		p, q = q, p
 	}
-
-	totalWeight := 0
-	for _, weight := range typeWeights { totalWeight += weight }
-	if totalWeight == 0 { return false }
-
-	pick := rand.Intn(totalWeight)
-	current := 0
-	var selected TileType
-	for _, p := range tile.Possibilities {
-		current += typeWeights[p]
-		if pick < current {
-			selected = p
-			break
-		}
+	switch {
+	case p == q:
+		return p != bank
+	case p == bank:
+		return q == wet
+	case p == wet:
+		return q == 0
 	}
-
-	tile.Type = selected
-	tile.Collapsed = true
-	tile.Possibilities = []TileType{selected}
-
-	w.Propagate(x, y)
 	return false
 }
 
-func (w *WFC) Propagate(x, y int) {
-	stack := [][2]int{{x, y}}
-	seen := make(map[[2]int]bool)
-
-	for len(stack) > 0 {
-		curr := stack[0]
-		stack = stack[1:]
-		cx, cy := curr[0], curr[1]
-
-		// Directions: 0:Up, 1:Right, 2:Down, 3:Left
-		dirs := [][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}}
-
-		for d, offset := range dirs {
-			nx, ny := cx+offset[0], cy+offset[1]
-			if nx < 0 || nx >= w.Width || ny < 0 || ny >= w.Height { continue }
-			if w.Grid[ny][nx].Collapsed { continue }
-
-			// Opposite direction index for neighbor
-			// Up(0) -> Down(2), Right(1) -> Left(3), Down(2) -> Up(0), Left(3) -> Right(1)
-			oppDir := (d + 2) % 4
-
-			// Valid possibilities for neighbor at (nx, ny)
-			// based on the possibilities of current tile at (cx, cy)
-			allowedForNeighbor := make(map[TileType]bool)
-			for _, p := range w.Grid[cy][cx].Possibilities {
-				pSocket := sockets[p][d] // My socket in this direction
-				
-				// Neighbor's socket in the opposite direction must match
-				for targetType, targetSockets := range sockets {
-					if targetSockets[oppDir] == pSocket {
-						allowedForNeighbor[targetType] = true
-					}
-				}
-			}
-
-			newPossibilities := []TileType{}
-			changed := false
-			for _, p := range w.Grid[ny][nx].Possibilities {
-				if allowedForNeighbor[p] {
-					newPossibilities = append(newPossibilities, p)
-				} else {
-					changed = true
-				}
-			}
-
-			if changed {
-				w.Grid[ny][nx].Possibilities = newPossibilities
-				if !seen[[2]int{nx, ny}] {
-					stack = append(stack, [2]int{nx, ny})
-					seen[[2]int{nx, ny}] = true
-				}
-			}
-		}
+// hexSocket reports whether hex tile t has a road exit in direction dir.
+// Blocks have none.
+func hexSocket(t TileType, dir int) bool {
+	if t < hexRoadBase {
+		return false
 	}
+	return hexRoadMasks[t-hexRoadBase]&(1<<dir) != 0
 }
 
-func (w *WFC) Step() bool {
-	return w.Collapse()
+// hexSocketsMatch is socketsMatch for the six sides of a hex.
+func hexSocketsMatch(a, b TileType, dir int) bool {
+	return hexSocket(a, dir) == hexSocket(b, (dir+3)%6)
+}
+
+// seedCity starts the grid from a central crossroads and a patch of water
+// at (wx, wy), the heart of the waterfront.
+func seedCity(s *solver.Solver[TileType], wx, wy int) {
+	cross := RoadCross
+	if _, ok := s.Topology.(solver.Hex); ok {
+		cross = hexCross
+	}
+	s.Pin(s.Width/2, s.Height/2, cross)
+	s.Pin(wx, wy, Water)
 }
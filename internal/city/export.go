@@ -0,0 +1,25 @@
+package city
+
+import "atlas.games/internal/mapio"
+
+// Map snapshots the grid for the writers in mapio. Cells that have not
+// collapsed yet are reported as mapio.Unresolved.
+func (w *WFC) Map() *mapio.Map {
+	return w.Export("city", cell)
+}
+
+// cell is t's palette entry with its road sockets as links, so image
+// writers can draw streets rather than blocks.
+func cell(t TileType) mapio.Cell {
+	c := tileInfo[t]
+	if t >= hexRoadBase {
+		c.Links = hexRoadMasks[t-hexRoadBase]
+		return c
+	}
+	for d, s := range sockets[t] {
+		if s == 1 {
+			c.Links |= 1 << d
+		}
+	}
+	return c
+}
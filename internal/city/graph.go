@@ -0,0 +1,364 @@
+package city
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// Node kinds in a road graph.
+const (
+	NodeIntersection = "intersection" // Three or more roads meet
+	NodeDeadEnd      = "dead_end"     // Only one road leads here
+	NodeExit         = "exit"         // The road leaves the map here
+	NodeLoop         = "loop"         // Stands in for a ring road with no junctions
+)
+
+// Node is a junction, dead end or exit of the road graph.
+type Node struct {
+	ID     int    `json:"id"`
+	X      int    `json:"x"`
+	Y      int    `json:"y"`
+	Kind   string `json:"kind"`
+	Degree int    `json:"degree"` // Road tiles linked to this one
+}
+
+// Edge is a run of road between two nodes. Length is the number of steps
+// from one end to the other, so adjacent nodes are 1 apart.
+type Edge struct {
+	ID      int      `json:"id"`
+	From    int      `json:"from"`
+	To      int      `json:"to"`
+	Length  int      `json:"length"`
+	Bridges int      `json:"bridges"`
+	Path    [][2]int `json:"path"` // Cells from From to To, both included
+}
+
+// Block is a connected area of blocks between the roads, with the roads
+// along its border.
+type Block struct {
+	ID    int   `json:"id"`
+	Cells int   `json:"cells"`
+	Edges []int `json:"edges"` // Edges running past it
+	Nodes []int `json:"nodes"` // Nodes on its corners
+}
+
+// Lot is a building, shop, factory or footprint and the block it sits on.
+// Footprints are listed once, at their top left cell.
+type Lot struct {
+	X     int    `json:"x"`
+	Y     int    `json:"y"`
+	Kind  string `json:"kind"`
+	Block int    `json:"block"`
+}
+
+// Graph is the road network of a finished city.
+type Graph struct {
+	Seed      int64   `json:"seed"`
+	Width     int     `json:"width"`
+	Height    int     `json:"height"`
+	Nodes     []Node  `json:"nodes"`
+	Edges     []Edge  `json:"edges"`
+	Blocks    []Block `json:"blocks"`
+	Buildings []Lot   `json:"buildings"`
+}
+
+// buildingKinds names the tiles that count as buildings, by their top
+// left part for footprints.
+var buildingKinds = map[TileType]string{
+	Building:   "building",
+	Commercial: "commercial",
+	Factory:    "factory",
+	TowerTL:    "tower",
+	MallTL:     "mall",
+}
+
+// exits lists the directions the road at (x, y) is linked to a neighbor
+// in.
+func (w *WFC) exits(x, y int) []int {
+	var dirs []int
+	t := w.Grid[y][x].Type
+	for d := 0; d < w.Topology.Dirs(); d++ {
+		if !w.road(t, d) {
+			continue
+		}
+		nx, ny, ok := w.Topology.Step(x, y, d, w.Width, w.Height)
+		if ok && w.road(w.Grid[ny][nx].Type, w.Topology.Opposite(d)) {
+			dirs = append(dirs, d)
+		}
+	}
+	return dirs
+}
+
+// Graph extracts the road graph of the finished city. Every road tile that
+// is not a plain run between two others is a node: junctions, dead ends
+// and exits off the map edge. The runs between them become edges, and a
+// ring road with no junction gets one loop node. Blocks are found the way
+// the network pass finds districts.
+func (w *WFC) Graph() *Graph {
+	g := &Graph{Seed: w.Seed, Width: w.Width, Height: w.Height}
+	node := make([][]int, w.Height)
+	edgeAt := make([][]int, w.Height)
+	for y := range node {
+		node[y] = make([]int, w.Width)
+		edgeAt[y] = make([]int, w.Width)
+		for x := range node[y] {
+			node[y][x], edgeAt[y][x] = -1, -1
+		}
+	}
+
+	addNode := func(x, y int, kind string) {
+		node[y][x] = len(g.Nodes)
+		g.Nodes = append(g.Nodes, Node{ID: len(g.Nodes), X: x, Y: y, Kind: kind, Degree: len(w.exits(x, y))})
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			t := w.Grid[y][x].Type
+			if !isRoad(t) {
+				continue
+			}
+			switch n := len(w.exits(x, y)); {
+			case w.offEdge(x, y, t):
+				addNode(x, y, NodeExit)
+			case n >= 3:
+				addNode(x, y, NodeIntersection)
+			case n <= 1:
+				addNode(x, y, NodeDeadEnd)
+			}
+		}
+	}
+
+	// used marks the half-edges already walked, as node*dirs+dir
+	used := map[int]bool{}
+	dirs := w.Topology.Dirs()
+	walkFrom := func(id int) {
+		n := g.Nodes[id]
+		for _, d := range w.exits(n.X, n.Y) {
+			if used[id*dirs+d] {
+				continue
+			}
+			e := Edge{ID: len(g.Edges), From: id, Path: [][2]int{{n.X, n.Y}}}
+			x, y, dir := n.X, n.Y, d
+			for {
+				x, y, _ = w.Topology.Step(x, y, dir, w.Width, w.Height)
+				e.Path = append(e.Path, [2]int{x, y})
+				e.Length++
+				if t := w.Grid[y][x].Type; t == BridgeV || t == BridgeH {
+					e.Bridges++
+				}
+				if node[y][x] >= 0 {
+					break
+				}
+				edgeAt[y][x] = e.ID
+				back := w.Topology.Opposite(dir)
+				for _, next := range w.exits(x, y) {
+					if next != back {
+						dir = next
+						break
+					}
+				}
+			}
+			e.To = node[y][x]
+			used[id*dirs+d] = true
+			used[e.To*dirs+w.Topology.Opposite(dir)] = true
+			g.Edges = append(g.Edges, e)
+		}
+	}
+	for id := range g.Nodes {
+		walkFrom(id)
+	}
+	// Whatever is left is ring roads with no junction on them
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if isRoad(w.Grid[y][x].Type) && node[y][x] < 0 && edgeAt[y][x] < 0 {
+				addNode(x, y, NodeLoop)
+				walkFrom(node[y][x])
+			}
+		}
+	}
+
+	w.extractBlocks(g, node, edgeAt)
+	return g
+}
+
+// extractBlocks fills in the blocks and buildings of g. node and edgeAt
+// are the node and edge of each road cell, -1 for none.
+func (w *WFC) extractBlocks(g *Graph, node, edgeAt [][]int) {
+	block := make([][]int, w.Height)
+	for y := range block {
+		block[y] = make([]int, w.Width)
+		for x := range block[y] {
+			block[y][x] = -1
+		}
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if block[y][x] >= 0 || isRoad(w.Grid[y][x].Type) {
+				continue
+			}
+			b := Block{ID: len(g.Blocks)}
+			edges, nodes := map[int]bool{}, map[int]bool{}
+			block[y][x] = b.ID
+			stack := [][2]int{{x, y}}
+			for len(stack) > 0 {
+				c := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				b.Cells++
+				w.neighbors(c[0], c[1], func(nx, ny int) {
+					switch {
+					case edgeAt[ny][nx] >= 0:
+						edges[edgeAt[ny][nx]] = true
+					case node[ny][nx] >= 0:
+						nodes[node[ny][nx]] = true
+					case block[ny][nx] < 0 && !isRoad(w.Grid[ny][nx].Type):
+						block[ny][nx] = b.ID
+						stack = append(stack, [2]int{nx, ny})
+					}
+				})
+			}
+			b.Edges, b.Nodes = slices.Sorted(maps.Keys(edges)), slices.Sorted(maps.Keys(nodes))
+			g.Blocks = append(g.Blocks, b)
+		}
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if kind, ok := buildingKinds[w.Grid[y][x].Type]; ok {
+				g.Buildings = append(g.Buildings, Lot{X: x, Y: y, Kind: kind, Block: block[y][x]})
+			}
+		}
+	}
+}
+
+// WriteJSON writes the graph as one JSON object.
+func (g *Graph) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(g)
+}
+
+type graphmlKey struct {
+	ID   string `xml:"id,attr"`
+	For  string `xml:"for,attr"`
+	Name string `xml:"attr.name,attr"`
+	Type string `xml:"attr.type,attr"`
+}
+
+type graphmlData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphmlNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphmlData `xml:"data"`
+}
+
+type graphmlEdge struct {
+	ID     string        `xml:"id,attr"`
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphmlData `xml:"data"`
+}
+
+type graphmlGraph struct {
+	ID          string        `xml:"id,attr"`
+	EdgeDefault string        `xml:"edgedefault,attr"`
+	Nodes       []graphmlNode `xml:"node"`
+	Edges       []graphmlEdge `xml:"edge"`
+}
+
+type graphmlDoc struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphmlKey `xml:"key"`
+	Graph   graphmlGraph `xml:"graph"`
+}
+
+// graphmlKeys are the attributes of the GraphML export. Buildings are
+// nodes too, joined to nothing, with the block they sit on.
+var graphmlKeys = []graphmlKey{
+	{ID: "x", For: "node", Name: "x", Type: "int"},
+	{ID: "y", For: "node", Name: "y", Type: "int"},
+	{ID: "kind", For: "node", Name: "kind", Type: "string"},
+	{ID: "degree", For: "node", Name: "degree", Type: "int"},
+	{ID: "block", For: "node", Name: "block", Type: "int"},
+	{ID: "length", For: "edge", Name: "length", Type: "int"},
+	{ID: "bridges", For: "edge", Name: "bridges", Type: "int"},
+	{ID: "path", For: "edge", Name: "path", Type: "string"},
+}
+
+// WriteGraphML writes the graph as undirected GraphML, with road nodes as
+// n<id>, buildings as b<index> and edges as e<id>. Edge paths are
+// "x,y x,y ..." strings.
+func (g *Graph) WriteGraphML(w io.Writer) error {
+	doc := graphmlDoc{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys:  graphmlKeys,
+		Graph: graphmlGraph{ID: fmt.Sprintf("city-%d", g.Seed), EdgeDefault: "undirected"},
+	}
+	for _, n := range g.Nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{ID: fmt.Sprintf("n%d", n.ID), Data: []graphmlData{
+			{Key: "x", Value: fmt.Sprint(n.X)},
+			{Key: "y", Value: fmt.Sprint(n.Y)},
+			{Key: "kind", Value: n.Kind},
+			{Key: "degree", Value: fmt.Sprint(n.Degree)},
+		}})
+	}
+	for i, b := range g.Buildings {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphmlNode{ID: fmt.Sprintf("b%d", i), Data: []graphmlData{
+			{Key: "x", Value: fmt.Sprint(b.X)},
+			{Key: "y", Value: fmt.Sprint(b.Y)},
+			{Key: "kind", Value: b.Kind},
+			{Key: "block", Value: fmt.Sprint(b.Block)},
+		}})
+	}
+	for _, e := range g.Edges {
+		cells := make([]string, len(e.Path))
+		for i, c := range e.Path {
+			cells[i] = fmt.Sprintf("%d,%d", c[0], c[1])
+		}
+		doc.Graph.Edges = append(doc.Graph.Edges, graphmlEdge{
+			ID:     fmt.Sprintf("e%d", e.ID),
+			Source: fmt.Sprintf("n%d", e.From),
+			Target: fmt.Sprintf("n%d", e.To),
+			Data: []graphmlData{
+				{Key: "length", Value: fmt.Sprint(e.Length)},
+				{Key: "bridges", Value: fmt.Sprint(e.Bridges)},
+				{Key: "path", Value: strings.Join(cells, " ")},
+			},
+		})
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// SaveGraph writes g to the named file, as GraphML if the name ends in
+// .graphml and as JSON otherwise.
+func SaveGraph(name string, g *Graph) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	write := g.WriteJSON
+	if strings.HasSuffix(name, ".graphml") {
+		write = g.WriteGraphML
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,148 @@
+package city
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"atlas.games/internal/solver"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// sketch builds a finished square city from rows of tile glyphs, with
+// spaces left Empty. The mall's top and bottom share a glyph, so "━" is
+// always MallT.
+func sketch(t *testing.T, rows ...string) *WFC {
+	t.Helper()
+	glyphs := map[rune]TileType{' ': Empty}
+	for i := len(allTypes) - 1; i >= 0; i-- {
+		glyphs[[]rune(allTypes[i].Glyph())[0]] = allTypes[i]
+	}
+	width := len([]rune(rows[0]))
+	s := solver.New(width, len(rows), 1, solver.Square{}, solver.Rules[TileType]{
+		Tiles:  allTypes,
+		Weight: func(TileType) int { return 1 },
+		Allows: func(a, b TileType, dir int) bool { return true },
+	})
+	for y, row := range rows {
+		if len([]rune(row)) != width {
+			t.Fatalf("row %d is not %d tiles wide", y, width)
+		}
+		for x, r := range []rune(row) {
+			tile, ok := glyphs[r]
+			if !ok {
+				t.Fatalf("no tile is drawn %q", r)
+			}
+			s.Grid[y][x] = Tile{Type: tile, Collapsed: true}
+		}
+	}
+	return &WFC{Solver: s}
+}
+
+// sampleCity has two exits into a crossroads, a ring road with a bridge
+// on it, and buildings in four blocks.
+func sampleCity(t *testing.T) *WFC {
+	return sketch(t,
+		"█║███",
+		"═╬══╗",
+		"█║♣█╫",
+		"█╚══╝",
+	)
+}
+
+func TestGraph(t *testing.T) {
+	g := sampleCity(t).Graph()
+	wantNodes := []Node{
+		{ID: 0, X: 1, Y: 0, Kind: NodeExit, Degree: 1},
+		{ID: 1, X: 0, Y: 1, Kind: NodeExit, Degree: 1},
+		{ID: 2, X: 1, Y: 1, Kind: NodeIntersection, Degree: 4},
+	}
+	if !reflect.DeepEqual(g.Nodes, wantNodes) {
+		t.Errorf("nodes = %+v, want %+v", g.Nodes, wantNodes)
+	}
+	wantEdges := []struct{ from, to, length, bridges int }{
+		{0, 2, 1, 0},
+		{1, 2, 1, 0},
+		{2, 2, 10, 1}, // The ring, from the crossroads back to it
+	}
+	if len(g.Edges) != len(wantEdges) {
+		t.Fatalf("got %d edges, want %d: %+v", len(g.Edges), len(wantEdges), g.Edges)
+	}
+	for i, want := range wantEdges {
+		e := g.Edges[i]
+		if e.From != want.from || e.To != want.to || e.Length != want.length || e.Bridges != want.bridges {
+			t.Errorf("edge %d = %d-%d length %d bridges %d, want %+v", i, e.From, e.To, e.Length, e.Bridges, want)
+		}
+		if len(e.Path) != e.Length+1 {
+			t.Errorf("edge %d: %d steps over %d cells", i, e.Length, len(e.Path))
+		}
+	}
+	wantBlocks := []Block{
+		{ID: 0, Cells: 1, Nodes: []int{0, 1}},
+		{ID: 1, Cells: 3, Nodes: []int{0}, Edges: []int{2}},
+		{ID: 2, Cells: 2, Nodes: []int{1}, Edges: []int{2}},
+		{ID: 3, Cells: 2, Edges: []int{2}},
+	}
+	if !reflect.DeepEqual(g.Blocks, wantBlocks) {
+		t.Errorf("blocks = %+v, want %+v", g.Blocks, wantBlocks)
+	}
+	if len(g.Buildings) != 7 {
+		t.Errorf("got %d buildings, want 7: %+v", len(g.Buildings), g.Buildings)
+	}
+	for _, b := range g.Buildings {
+		if b.Block < 0 || b.Kind != "building" {
+			t.Errorf("building %+v", b)
+		}
+	}
+}
+
+func TestGraphLoop(t *testing.T) {
+	g := sketch(t,
+		"╔═╗",
+		"║█║",
+		"╚═╝",
+	).Graph()
+	if len(g.Nodes) != 1 || g.Nodes[0].Kind != NodeLoop {
+		t.Fatalf("nodes = %+v, want one loop", g.Nodes)
+	}
+	if len(g.Edges) != 1 || g.Edges[0].Length != 8 || g.Edges[0].From != 0 || g.Edges[0].To != 0 {
+		t.Errorf("edges = %+v, want one of length 8 round the loop", g.Edges)
+	}
+}
+
+func TestGraphGolden(t *testing.T) {
+	g := sampleCity(t).Graph()
+	g.Seed = 7
+	tests := []struct {
+		name  string
+		write func(*bytes.Buffer) error
+	}{
+		{"graph.json", func(b *bytes.Buffer) error { return g.WriteJSON(b) }},
+		{"graph.graphml", func(b *bytes.Buffer) error { return g.WriteGraphML(b) }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("output differs from %s:\n%s", golden, buf.String())
+			}
+		})
+	}
+}
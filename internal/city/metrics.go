@@ -0,0 +1,293 @@
+package city
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Report is the metrics of a finished city: land use, how far homes are
+// from green space and shops, and how well the roads are joined up.
+type Report struct {
+	Seed       int64       `json:"seed"`
+	Width      int         `json:"width"`
+	Height     int         `json:"height"`
+	Tiles      []TileShare `json:"tiles"`
+	Buildings  int         `json:"buildings"`
+	ParkWalk   Walk        `json:"park_walk"`
+	ShopWalk   Walk        `json:"shop_walk"`
+	Roads      int         `json:"roads"`
+	Junctions  int         `json:"junctions"`
+	DeadEnds   int         `json:"dead_ends"`
+	Livability float64     `json:"livability"`
+}
+
+// TileShare is how much of the city one tile type covers.
+type TileShare struct {
+	Tile  string  `json:"tile"`
+	Count int     `json:"count"`
+	Share float64 `json:"share"`
+}
+
+// Walk is the walking distance, in steps, from every Building to the
+// nearest of some kind of tile. Unreached counts the buildings with no
+// walk to one at all; they are left out of the mean and max.
+type Walk struct {
+	Mean      float64 `json:"mean"`
+	Max       int     `json:"max"`
+	Within    float64 `json:"within"` // Share of the buildings within the limit, see parkWalk
+	Unreached int     `json:"unreached"`
+}
+
+const (
+	// parkWalk and shopWalk are the walks a home should be within for the
+	// livability score.
+	parkWalk = 10
+	shopWalk = 8
+	// greenTarget is the park share that earns full marks for green space.
+	greenTarget = 0.15
+	// Weights of the livability score parts, adding up to 100.
+	parkWeight, shopWeight, greenWeight, roadWeight = 35, 35, 15, 15
+)
+
+// walkable reports whether pedestrians can cross t: roads, bridges and
+// parks. Other tiles are only walked into, as a destination.
+func walkable(t TileType) bool {
+	return isRoad(t) || t == Park
+}
+
+// isShop reports whether t is a shop: a Commercial tile or part of a mall.
+func isShop(t TileType) bool {
+	return t == Commercial || (t >= MallTL && t <= MallBR)
+}
+
+// walkFrom is the walking distance of every walkable cell to the nearest
+// cell for which target holds, or -1 where none can be reached. Targets
+// that are not walkable themselves are reached from a cell next to them.
+func (w *WFC) walkFrom(target func(TileType) bool) [][]int {
+	dist := make([][]int, w.Height)
+	for y := range dist {
+		dist[y] = make([]int, w.Width)
+		for x := range dist[y] {
+			dist[y][x] = -1
+		}
+	}
+	// Walkable targets first, then the cells next to the others, so the
+	// queue starts in order of distance
+	var queue [][2]int
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if t := w.Grid[y][x].Type; target(t) && walkable(t) {
+				dist[y][x] = 0
+				queue = append(queue, [2]int{x, y})
+			}
+		}
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if t := w.Grid[y][x].Type; !target(t) || walkable(t) {
+				continue
+			}
+			w.neighbors(x, y, func(nx, ny int) {
+				if walkable(w.Grid[ny][nx].Type) && dist[ny][nx] < 0 {
+					dist[ny][nx] = 1
+					queue = append(queue, [2]int{nx, ny})
+				}
+			})
+		}
+	}
+	for i := 0; i < len(queue); i++ {
+		c := queue[i]
+		w.neighbors(c[0], c[1], func(nx, ny int) {
+			if walkable(w.Grid[ny][nx].Type) && dist[ny][nx] < 0 {
+				dist[ny][nx] = dist[c[1]][c[0]] + 1
+				queue = append(queue, [2]int{nx, ny})
+			}
+		})
+	}
+	return dist
+}
+
+// walk measures the walks from every Building to the cells of dist, as
+// from walkFrom. A building next to a target is one step away; otherwise
+// it walks out through a cell next to it. A building inside a block first
+// crosses the block to the cell it leaves by, and those steps count too.
+func (w *WFC) walk(dist [][]int, target func(TileType) bool, limit int) Walk {
+	var out Walk
+	total, reached, within := 0, 0, 0
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if w.Grid[y][x].Type != Building {
+				continue
+			}
+			best := w.walkOut(dist, target, x, y)
+			if best < 0 {
+				out.Unreached++
+				continue
+			}
+			total += best
+			reached++
+			out.Max = max(out.Max, best)
+			if best <= limit {
+				within++
+			}
+		}
+	}
+	if reached > 0 {
+		out.Mean = float64(total) / float64(reached)
+	}
+	if n := reached + out.Unreached; n > 0 {
+		out.Within = float64(within) / float64(n)
+	}
+	return out
+}
+
+// walkOut is the shortest walk from the building at (x, y) to a cell of
+// dist, or -1 if there is none. It searches outward across the lots of the
+// block, one step per cell crossed and never through water, and stops once
+// a further cell could not do better.
+func (w *WFC) walkOut(dist [][]int, target func(TileType) bool, x, y int) int {
+	best := -1
+	steps := map[[2]int]int{{x, y}: 0}
+	queue := [][2]int{{x, y}}
+	for i := 0; i < len(queue); i++ {
+		c := queue[i]
+		k := steps[c]
+		if best >= 0 && k+1 >= best {
+			break
+		}
+		w.neighbors(c[0], c[1], func(nx, ny int) {
+			t := w.Grid[ny][nx].Type
+			d := dist[ny][nx]
+			if target(t) {
+				d = 0
+			}
+			if d >= 0 && (best < 0 || k+d+1 < best) {
+				best = k + d + 1
+			}
+			if n := [2]int{nx, ny}; !walkable(t) && !target(t) && t != Water {
+				if _, ok := steps[n]; !ok {
+					steps[n] = k + 1
+					queue = append(queue, n)
+				}
+			}
+		})
+	}
+	return best
+}
+
+// Report measures the finished city. The livability score, from 0 to 100,
+// weighs the share of homes within parkWalk of a park and shopWalk of a
+// shop, the park share against greenTarget, and the share of road ends
+// that are junctions rather than dead ends.
+func (w *WFC) Report() *Report {
+	r := &Report{Seed: w.Seed, Width: w.Width, Height: w.Height}
+	counts := map[TileType]int{}
+	exits := map[[2]int]bool{}
+	for _, c := range w.exitCells() {
+		exits[c] = true
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			t := w.Grid[y][x].Type
+			counts[t]++
+			switch {
+			case t == Building:
+				r.Buildings++
+			case isRoad(t):
+				r.Roads++
+				switch n := len(w.exits(x, y)); {
+				case n >= 3:
+					r.Junctions++
+				case n <= 1 && !exits[[2]int{x, y}]:
+					r.DeadEnds++
+				}
+			}
+		}
+	}
+	area := float64(w.Width * w.Height)
+	for t := TileType(0); t <= hexCross; t++ {
+		if counts[t] > 0 {
+			r.Tiles = append(r.Tiles, TileShare{Tile: t.String(), Count: counts[t], Share: float64(counts[t]) / area})
+		}
+	}
+
+	isPark := func(t TileType) bool { return t == Park }
+	r.ParkWalk = w.walk(w.walkFrom(isPark), isPark, parkWalk)
+	r.ShopWalk = w.walk(w.walkFrom(isShop), isShop, shopWalk)
+
+	green := min(1, float64(counts[Park])/area/greenTarget)
+	roads := 1.0
+	if ends := r.Junctions + r.DeadEnds; ends > 0 {
+		roads = float64(r.Junctions) / float64(ends)
+	}
+	r.Livability = parkWeight*r.ParkWalk.Within + shopWeight*r.ShopWalk.Within + greenWeight*green + roadWeight*roads
+	return r
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// SaveReport writes the report to the named file as JSON.
+func SaveReport(name string, r *Report) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := r.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// sidebarWidth is the width of the metrics sidebar in terminal cells.
+const sidebarWidth = 34
+
+var sidebarStyle = lipgloss.NewStyle().Width(sidebarWidth).Padding(0, 1).Border(lipgloss.NormalBorder(), false, false, false, true)
+
+// viewReport is the metrics sidebar: the livability score and its parts,
+// then the land use, largest share first.
+func viewReport(r *Report) string {
+	var sb strings.Builder
+	sb.WriteString(titleStyle.Render("CITY METRICS") + "\n\n")
+	sb.WriteString(fmt.Sprintf("Livability: %.0f/100\n\n", r.Livability))
+	walk := func(name string, wk Walk, limit int) {
+		sb.WriteString(fmt.Sprintf("%s walk: mean %.1f, max %d\n", name, wk.Mean, wk.Max))
+		sb.WriteString(fmt.Sprintf("  %.0f%% within %d", wk.Within*100, limit))
+		if wk.Unreached > 0 {
+			sb.WriteString(fmt.Sprintf(", %d cut off", wk.Unreached))
+		}
+		sb.WriteString("\n")
+	}
+	sb.WriteString(fmt.Sprintf("Homes: %d\n", r.Buildings))
+	walk("Park", r.ParkWalk, parkWalk)
+	walk("Shop", r.ShopWalk, shopWalk)
+	sb.WriteString(fmt.Sprintf("Roads: %d, %d junctions\n", r.Roads, r.Junctions))
+	sb.WriteString(fmt.Sprintf("Dead ends: %d\n\n", r.DeadEnds))
+
+	sb.WriteString("Land use:\n")
+	tiles := slices.Clone(r.Tiles)
+	slices.SortStableFunc(tiles, func(a, b TileShare) int { return b.Count - a.Count })
+	for _, t := range tiles {
+		sb.WriteString(fmt.Sprintf("  %-16s %5.1f%%\n", t.Tile, t.Share*100))
+	}
+	return sidebarStyle.Render(strings.TrimRight(sb.String(), "\n"))
+}
+
+// measure takes the report of a finished city for the metrics sidebar,
+// once per city.
+func (m *Model) measure() {
+	if m.metrics && m.done && m.report == nil {
+		m.report = m.wfc.Report()
+	}
+}
@@ -0,0 +1,56 @@
+package city
+
+import (
+	"math"
+	"testing"
+)
+
+func TestWalkOut(t *testing.T) {
+	tests := []struct {
+		name string
+		row  string
+		want int
+	}{
+		{"next to a shop", "█S", 1},
+		{"along the road", "█═══S", 4},
+		{"through a park", "█♣♣S", 3},
+		{"across the block", "██═S", 3},
+		{"across empty lots", "█ ═S", 3},
+		{"water in the way", "█~═S", -1},
+		{"no shop", "█═══", -1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sketch(t, tt.row)
+			if got := w.walkOut(w.walkFrom(isShop), isShop, 0, 0); got != tt.want {
+				t.Errorf("walkOut = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestWalkOutShortest has two ways out of the block: straight through the
+// building to the south onto a long road, or two lots east onto a short
+// one. The second is shorter.
+func TestWalkOutShortest(t *testing.T) {
+	w := sketch(t,
+		"███═S",
+		"█    ",
+		"═════",
+		"    S",
+	)
+	if got := w.walkOut(w.walkFrom(isShop), isShop, 0, 0); got != 4 {
+		t.Errorf("walkOut = %d, want 4", got)
+	}
+}
+
+func TestWalk(t *testing.T) {
+	// Walks of 1 and 2, and one building cut off by water
+	w := sketch(t, "█S═█~█")
+	got := w.walk(w.walkFrom(isShop), isShop, 1)
+	want := Walk{Mean: 1.5, Max: 2, Within: 1.0 / 3, Unreached: 1}
+	if got.Max != want.Max || got.Unreached != want.Unreached ||
+		math.Abs(got.Mean-want.Mean) > 1e-9 || math.Abs(got.Within-want.Within) > 1e-9 {
+		t.Errorf("walk = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,407 @@
+package city
+
+import (
+	"fmt"
+	"strings"
+
+	"atlas.games/internal/solver"
+)
+
+// maxRegenerations is how many times a city starts over because a required
+// exit was cut off from the rest of the roads. After that the network pass
+// keeps the best network it has and reports the missing exits.
+const maxRegenerations = 8
+
+// edgeNames are the map edges exits can be required on, indexed by solver
+// direction.
+var edgeNames = [4]string{"top", "right", "bottom", "left"}
+
+// ParseExits reads a comma-separated list of edges (top, right, bottom,
+// left) as solver directions. "all" selects every edge; "" and "none"
+// select none.
+func ParseExits(s string) ([]int, error) {
+	var exits []int
+	on := [4]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "", "none":
+			continue
+		case "all":
+			on = [4]bool{true, true, true, true}
+			continue
+		}
+		known := false
+		for d, n := range edgeNames {
+			if n == name {
+				on[d], known = true, true
+			}
+		}
+		if !known {
+			return nil, fmt.Errorf("unknown edge %q (want all, none or a list of %s)", name, strings.Join(edgeNames[:], ", "))
+		}
+	}
+	for d, ok := range on {
+		if ok {
+			exits = append(exits, d)
+		}
+	}
+	return exits, nil
+}
+
+// DescribeExits names exits for status lines, e.g. "top, bottom" or "none".
+func DescribeExits(exits []int) string {
+	if len(exits) == 0 {
+		return "none"
+	}
+	names := make([]string, len(exits))
+	for i, d := range exits {
+		names[i] = edgeNames[d]
+	}
+	return strings.Join(names, ", ")
+}
+
+// Network is what the network pass found in a finished city. Components,
+// Unreached and Dangling describe the grid the solver left; the rest
+// describe the city after the repair.
+type Network struct {
+	Checked    bool // The pass has run on the current grid
+	Components int  // Separate road networks the solver left
+	Unreached  int  // Districts that touched none of the kept roads
+	Removed    int  // Road tiles cleared with the smaller networks
+	Roads      int  // Road tiles in the network that was kept
+	Bridges    int  // Of which bridges
+	Districts  int  // Connected areas of blocks between the roads
+	Dangling   int  // Roads running off an unwrapped edge other than at an exit
+	Exits      int  // Required exits joined to the network
+	Parks      int  // Blocks the zoning pass turned into whole parks
+}
+
+// isRoad reports whether t is a square or hex road. Bridges count.
+func isRoad(t TileType) bool {
+	return (t >= RoadV && t <= RoadCross) || t == BridgeV || t == BridgeH || t >= hexRoadBase
+}
+
+// hex reports whether the city is on a hex grid.
+func (w *WFC) hex() bool {
+	_, ok := w.Topology.(solver.Hex)
+	return ok
+}
+
+// wrapped reports whether the city's opposite edges meet.
+func (w *WFC) wrapped() bool {
+	switch t := w.Topology.(type) {
+	case solver.Square:
+		return t.Wrap
+	case solver.Hex:
+		return t.Wrap
+	}
+	return false
+}
+
+// road reports whether t has a road exit in direction dir.
+func (w *WFC) road(t TileType, dir int) bool {
+	if w.hex() {
+		return hexSocket(t, dir)
+	}
+	return sockets[t][dir] == 1
+}
+
+// exitAt is the cell and tile of a required exit on edge: a straight road
+// across the middle of the edge.
+func (w *WFC) exitAt(edge int) (x, y int, t TileType) {
+	x, y = w.Width/2, w.Height/2
+	switch edge {
+	case solver.Up:
+		y = 0
+	case solver.Down:
+		y = w.Height - 1
+	case solver.Left:
+		x = 0
+	case solver.Right:
+		x = w.Width - 1
+	}
+	vertical := edge == solver.Up || edge == solver.Down
+	switch {
+	case w.hex() && vertical:
+		t = hexRoadBase + 1
+	case w.hex():
+		t = hexRoadBase
+	case vertical:
+		t = RoadV
+	default:
+		t = RoadH
+	}
+	return x, y, t
+}
+
+// pinExits places the required exits. Wrapped cities have no edges, so
+// they get none.
+func (w *WFC) pinExits(s *solver.Solver[TileType]) {
+	if w.wrapped() {
+		return
+	}
+	for _, edge := range w.Exits {
+		x, y, t := w.exitAt(edge)
+		s.Pin(x, y, t)
+	}
+}
+
+// closeEdges keeps roads from running off an unwrapped edge, except at the
+// exits, and bridges and quays from facing water beyond it.
+func (w *WFC) closeEdges(s *solver.Solver[TileType]) {
+	if w.wrapped() {
+		return
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if y > 0 && y < w.Height-1 && x > 0 && x < w.Width-1 {
+				continue
+			}
+			if s.Grid[y][x].Collapsed {
+				continue
+			}
+			s.Restrict(x, y, func(t TileType) bool { return !w.offEdge(x, y, t) && !w.bankOff(x, y, t) })
+		}
+	}
+}
+
+// offEdge reports whether t at (x, y) has a road exit leaving the grid.
+func (w *WFC) offEdge(x, y int, t TileType) bool {
+	for d := 0; d < w.Topology.Dirs(); d++ {
+		if _, _, ok := w.Topology.Step(x, y, d, w.Width, w.Height); !ok && w.road(t, d) {
+			return true
+		}
+	}
+	return false
+}
+
+// bankOff reports whether t at (x, y) has a water bank facing off the grid.
+func (w *WFC) bankOff(x, y int, t TileType) bool {
+	if w.hex() {
+		return false
+	}
+	for d := 0; d < 4; d++ {
+		if _, _, ok := w.Topology.Step(x, y, d, w.Width, w.Height); !ok && sockets[t][d] == bank {
+			return true
+		}
+	}
+	return false
+}
+
+// linked calls fn with each neighbor of (x, y) its road leads to.
+func (w *WFC) linked(x, y int, fn func(nx, ny int)) {
+	t := w.Grid[y][x].Type
+	for d := 0; d < w.Topology.Dirs(); d++ {
+		if !w.road(t, d) {
+			continue
+		}
+		nx, ny, ok := w.Topology.Step(x, y, d, w.Width, w.Height)
+		if ok && w.road(w.Grid[ny][nx].Type, w.Topology.Opposite(d)) {
+			fn(nx, ny)
+		}
+	}
+}
+
+// neighbors calls fn with each neighbor of (x, y) on the grid.
+func (w *WFC) neighbors(x, y int, fn func(nx, ny int)) {
+	for d := 0; d < w.Topology.Dirs(); d++ {
+		if nx, ny, ok := w.Topology.Step(x, y, d, w.Width, w.Height); ok {
+			fn(nx, ny)
+		}
+	}
+}
+
+// components flood-fills the roads along their sockets. It returns each
+// cell's network, -1 for blocks, and the size of every network.
+func (w *WFC) components() (comp [][]int, sizes []int) {
+	comp = make([][]int, w.Height)
+	for y := range comp {
+		comp[y] = make([]int, w.Width)
+		for x := range comp[y] {
+			comp[y][x] = -1
+		}
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if comp[y][x] >= 0 || !isRoad(w.Grid[y][x].Type) {
+				continue
+			}
+			id := len(sizes)
+			sizes = append(sizes, 0)
+			comp[y][x] = id
+			stack := [][2]int{{x, y}}
+			for len(stack) > 0 {
+				c := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				sizes[id]++
+				w.linked(c[0], c[1], func(nx, ny int) {
+					if comp[ny][nx] < 0 {
+						comp[ny][nx] = id
+						stack = append(stack, [2]int{nx, ny})
+					}
+				})
+			}
+		}
+	}
+	return comp, sizes
+}
+
+// districts flood-fills the blocks between the roads and counts the
+// districts, and those with no road of network main along their border.
+func (w *WFC) districts(comp [][]int, main int) (n, unreached int) {
+	seen := make([][]bool, w.Height)
+	for y := range seen {
+		seen[y] = make([]bool, w.Width)
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if seen[y][x] || comp[y][x] >= 0 {
+				continue
+			}
+			n++
+			reached := false
+			seen[y][x] = true
+			stack := [][2]int{{x, y}}
+			for len(stack) > 0 {
+				c := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				w.neighbors(c[0], c[1], func(nx, ny int) {
+					switch {
+					case comp[ny][nx] >= 0:
+						reached = reached || comp[ny][nx] == main
+					case !seen[ny][nx]:
+						seen[ny][nx] = true
+						stack = append(stack, [2]int{nx, ny})
+					}
+				})
+			}
+			if !reached {
+				unreached++
+			}
+		}
+	}
+	return n, unreached
+}
+
+// Connect runs the network pass on a finished grid. It finds the road
+// networks by following sockets and keeps the one that joins the most
+// required exits, the largest on a tie. The others are cleared into the
+// blocks around them, which leaves every district on the kept network.
+// When a required exit is cut off from it, the city starts over instead,
+// up to maxRegenerations times, and Connect returns false so the caller
+// keeps stepping.
+func (w *WFC) Connect() bool {
+	if w.Network.Checked {
+		return true
+	}
+	comp, sizes := w.components()
+	exits := make([]int, len(sizes))
+	for _, c := range w.exitCells() {
+		if id := comp[c[1]][c[0]]; id >= 0 {
+			exits[id]++
+		}
+	}
+	main := -1
+	for id := range sizes {
+		if main < 0 || exits[id] > exits[main] || (exits[id] == exits[main] && sizes[id] > sizes[main]) {
+			main = id
+		}
+	}
+	joined := 0
+	if main >= 0 {
+		joined = exits[main]
+	}
+	if joined < len(w.exitCells()) && w.Regenerated < maxRegenerations {
+		w.Regenerated++
+		w.Restart()
+		return false
+	}
+
+	net := Network{Checked: true, Components: len(sizes), Exits: joined}
+	_, net.Unreached = w.districts(comp, main)
+	if main >= 0 {
+		net.Roads = sizes[main]
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if comp[y][x] < 0 || comp[y][x] == main {
+				continue
+			}
+			w.Overwrite(x, y, w.fill(x, y))
+			comp[y][x] = -1
+			net.Removed++
+		}
+	}
+	net.Parks = w.plantParks(comp)
+	net.Districts, _ = w.districts(comp, main)
+
+	exitCells := map[[2]int]bool{}
+	for _, c := range w.exitCells() {
+		exitCells[c] = true
+	}
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if comp[y][x] != main || main < 0 {
+				continue
+			}
+			if t := w.Grid[y][x].Type; t == BridgeV || t == BridgeH {
+				net.Bridges++
+			}
+			if !exitCells[[2]int{x, y}] && w.offEdge(x, y, w.Grid[y][x].Type) {
+				net.Dangling++
+			}
+		}
+	}
+	w.Network = net
+	return true
+}
+
+// exitCells are the cells of the required exits, none on a wrapped map.
+func (w *WFC) exitCells() [][2]int {
+	if w.wrapped() {
+		return nil
+	}
+	cells := make([][2]int, 0, len(w.Exits))
+	for _, edge := range w.Exits {
+		x, y, _ := w.exitAt(edge)
+		cells = append(cells, [2]int{x, y})
+	}
+	return cells
+}
+
+// fill is the block a cleared road at (x, y) turns into: the one most
+// common around it, Building if none. A cleared bridge has water on both
+// sides, so it turns into water.
+func (w *WFC) fill(x, y int) TileType {
+	if t := w.Grid[y][x].Type; t == BridgeV || t == BridgeH {
+		return Water
+	}
+	counts := map[TileType]int{}
+	w.neighbors(x, y, func(nx, ny int) {
+		if t := w.Grid[ny][nx].Type; !isRoad(t) {
+			counts[t]++
+		}
+	})
+	best := Building
+	for _, t := range []TileType{Building, Commercial, Park, Water} {
+		if counts[t] > counts[best] {
+			best = t
+		}
+	}
+	return best
+}
+
+// viewNetwork is the status line of the network pass.
+func (m Model) viewNetwork() string {
+	exits := DescribeExits(m.wfc.Exits)
+	if m.wrapped() && len(m.wfc.Exits) > 0 {
+		exits += " (ignored, wrapped)"
+	}
+	n := m.wfc.Network
+	if !n.Checked {
+		return fmt.Sprintf("  Network: pending | Exits: %s | Regenerated: %d | [O] Cycle Exits", exits, m.wfc.Regenerated)
+	}
+	return fmt.Sprintf("  Network: %d road tiles, %d bridges, %d districts, %d park blocks | Found %d networks, cleared %d tiles, %d districts cut off | Exits: %s (%d joined) | Regenerated: %d | [O] Cycle Exits",
+		n.Roads, n.Bridges, n.Districts, n.Parks, n.Components, n.Removed, n.Unreached, exits, n.Exits, m.wfc.Regenerated)
+}
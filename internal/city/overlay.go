@@ -0,0 +1,124 @@
+package city
+
+import (
+	"fmt"
+	"strings"
+
+	"atlas.games/internal/solver"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Overlay modes, cycled with V.
+const (
+	overlayOff = iota
+	overlayCount
+	overlayEntropy
+	overlayZones
+	overlayCongestion
+	overlayModes
+)
+
+var overlayNames = [overlayModes]string{"off", "possibilities", "entropy", "zones", "congestion"}
+
+// heatRamp colors undecided cells from nearly decided (green) to wide open
+// (red).
+var heatRamp = []lipgloss.Color{"22", "28", "64", "100", "136", "172", "166", "160", "124", "196"}
+
+var (
+	heatGlyph          = lipgloss.NewStyle().Foreground(lipgloss.Color("255"))
+	contradictionStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("196")).Bold(true)
+	cursorStyle        = lipgloss.NewStyle().Reverse(true)
+)
+
+// inspectLimit is how many candidates the inspector lists.
+const inspectLimit = 6
+
+// heat renders an undecided cell for the overlay: its possibility count on
+// a background scaled by count or entropy. frac is 0 for a single
+// remaining tile and 1 for a cell that could still be anything.
+func heat(n int, frac float64) string {
+	if n == 0 {
+		return contradictionStyle.Render("!")
+	}
+	glyph := "+"
+	if n <= 9 {
+		glyph = fmt.Sprint(n)
+	}
+	i := int(frac * float64(len(heatRamp)-1))
+	i = max(0, min(i, len(heatRamp)-1))
+	return heatGlyph.Background(heatRamp[i]).Render(glyph)
+}
+
+// heatOf is the overlay cell for (x, y) of a recorded solver.
+func heatOf[T ~int](s *solver.Solver[T], tl *solver.Timeline[T], mode, x, y int) string {
+	n := len(tl.Grid()[y][x].Possibilities)
+	frac := 0.0
+	switch mode {
+	case overlayCount:
+		if total := s.NumTiles(); total > 1 {
+			frac = float64(n-1) / float64(total-1)
+		}
+	case overlayEntropy:
+		if most := s.MaxEntropy(); most > 0 {
+			frac = tl.Entropy(x, y) / most
+		}
+	}
+	return heat(n, frac)
+}
+
+// describe is the inspector line for one cell.
+func describe[T ~int](in solver.Inspection[T], bonus int, name func(t int) string) string {
+	if in.Collapsed {
+		return fmt.Sprintf("  Cell %d,%d: collapsed to %s", in.X, in.Y, name(int(in.Tile)))
+	}
+	if len(in.Candidates) == 0 {
+		return fmt.Sprintf("  Cell %d,%d: contradiction, no candidates left", in.X, in.Y)
+	}
+	parts := []string{}
+	for i, c := range in.Candidates {
+		if i == inspectLimit {
+			parts = append(parts, fmt.Sprintf("+%d more", len(in.Candidates)-inspectLimit))
+			break
+		}
+		w := fmt.Sprint(c.Weight)
+		for j := 0; j < c.Bonuses; j++ {
+			w += fmt.Sprintf("x%d", bonus)
+		}
+		if c.Bonuses > 0 {
+			w += fmt.Sprintf("=%d", c.Effective)
+		}
+		parts = append(parts, fmt.Sprintf("%s %s (%.0f%%)", name(int(c.Tile)), w, c.Chance*100))
+	}
+	return fmt.Sprintf("  Cell %d,%d: %d candidates, entropy %.2f | %s", in.X, in.Y, len(in.Candidates), in.Entropy, strings.Join(parts, " | "))
+}
+
+// overlayCell is the overlay rendering of grid cell (x, y), with ok false
+// when the cell is collapsed and drawn as usual.
+func (m Model) overlayCell(x, y int) (cell string, ok bool) {
+	tl := m.wfc.Record()
+	if m.overlay == overlayOff || m.overlay >= overlayZones || tl.Grid()[y][x].Collapsed {
+		return "", false
+	}
+	return heatOf(m.wfc.Solver, tl, m.overlay, x, y), true
+}
+
+// inspect is the inspector line for the grid cell under the cursor, with
+// the zone its weights come from. City rules have no neighbor bonus.
+func (m Model) inspect() string {
+	x, y := (m.curX+m.rollX)%m.width, (m.curY+m.rollY)%m.height
+	return describe(m.wfc.Record().Inspect(x, y), 0, func(t int) string { return TileType(t).String() }) + " | zone " + m.wfc.ZoneAt(x, y).String()
+}
+
+// moveCursor moves the inspector cursor for an arrow key.
+func (m *Model) moveCursor(key string) {
+	switch key {
+	case "up":
+		m.curY = max(m.curY-1, 0)
+	case "down":
+		m.curY = min(m.curY+1, m.height-1)
+	case "left":
+		m.curX = max(m.curX-1, 0)
+	case "right":
+		m.curX = min(m.curX+1, m.width-1)
+	}
+}
@@ -0,0 +1,119 @@
+package city
+
+import (
+	"fmt"
+
+	"atlas.games/internal/solver"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// speeds are the frames per tick the viewer can play at; defaultSpeed is
+// the index it starts at.
+var speeds = []int{1, 5, 50, 500}
+
+const defaultSpeed = 2
+
+var (
+	observedBg = lipgloss.Color("94")
+	shrunkBg   = lipgloss.Color("17")
+)
+
+// player is the recorded solver on screen, see solver.Timeline.
+type player interface {
+	Len() int
+	Pos() int
+	Live() bool
+	Seek(pos int)
+	Forward() bool
+	Marks() solver.Marks
+}
+
+// player is the timeline of the city on screen. Recording starts the
+// first time it is asked for, before the first step.
+func (m Model) player() player {
+	return m.wfc.Record()
+}
+
+// advance moves the playback one frame on, running the network pass once
+// the live grid is full. It returns true when the city is done.
+func (m *Model) advance() bool {
+	return m.player().Forward() && m.wfc.Connect()
+}
+
+// updatePlayback handles the timeline keys. ok is false for other keys.
+func (m *Model) updatePlayback(key string) (cmd tea.Cmd, ok bool) {
+	p := m.player()
+	// Ticks stop while paused, or done with no traffic, so playing again
+	// restarts them
+	wasRunning := !m.paused && (!m.done || m.traffic != nil)
+	switch key {
+	case " ":
+		m.paused = !m.paused
+	case ".":
+		m.paused = true
+		m.done = m.advance()
+		m.measure()
+	case ",":
+		m.paused = true
+		p.Seek(p.Pos() - 1)
+	case "[", "]":
+		jump := max(1, p.Len()/20)
+		if key == "[" {
+			jump = -jump
+		}
+		p.Seek(p.Pos() + jump)
+	case "home":
+		p.Seek(0)
+	case "end":
+		p.Seek(p.Len())
+	case "-":
+		m.speed = max(0, m.speed-1)
+	case "+", "=":
+		m.speed = min(len(speeds)-1, m.speed+1)
+	default:
+		return nil, false
+	}
+	if !p.Live() {
+		m.done = false
+	}
+	if !wasRunning && !m.paused && (!m.done || m.traffic != nil) {
+		return tick(), true
+	}
+	return nil, true
+}
+
+// marked is style with the timeline highlight of (x, y), if any.
+func marked(style lipgloss.Style, marks solver.Marks, x, y int) lipgloss.Style {
+	if x == marks.X && y == marks.Y {
+		return style.Background(observedBg)
+	}
+	if marks.Shrunk[[2]int{x, y}] {
+		return style.Background(shrunkBg)
+	}
+	return style
+}
+
+// viewTimeline is the status line of the playback.
+func viewTimeline(p player, marks solver.Marks, paused bool, speed int, tileName func(t int) string) string {
+	state := fmt.Sprintf("Playing x%d", speeds[speed])
+	if paused {
+		state = "Paused"
+	}
+	at := "live"
+	if !p.Live() {
+		at = "replay"
+	}
+	last := "start"
+	switch {
+	case marks.Restarted:
+		last = "contradiction, restarted"
+	case marks.Backtracked:
+		last = fmt.Sprintf("contradiction, backtracked; %d cells shrank", len(marks.Shrunk))
+	case marks.X >= 0:
+		last = fmt.Sprintf("collapsed %d,%d to %s; %d cells shrank", marks.X, marks.Y, tileName(marks.Tile), len(marks.Shrunk))
+	case p.Pos() > 0:
+		last = "pins placed"
+	}
+	return fmt.Sprintf("  Frame %d/%d (%s) | %s | %s | [Space] Pause  [,/.] Step  [[/]] Scrub  [Home/End] Jump  [-/+] Speed", p.Pos(), p.Len(), at, state, last)
+}
@@ -0,0 +1,267 @@
+package city
+
+import (
+	"fmt"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Route is a way along the roads from one building to another.
+type Route struct {
+	Cells  [][2]int // Road cells in order, from the start's frontage to the destination's
+	Length int      // Steps from the first cell to the last
+	Turns  int      // Changes of direction along the way
+}
+
+// isBuilding reports whether t is a building, shop, factory or part of a
+// footprint.
+func isBuilding(t TileType) bool {
+	return t == Building || t == Commercial || t == Factory || isPart(t)
+}
+
+// lot is every cell of the building at (x, y): the whole footprint for a
+// footprint part, otherwise just the cell.
+func (w *WFC) lot(x, y int) [][2]int {
+	cells := [][2]int{{x, y}}
+	if w.hex() || !isPart(w.Grid[y][x].Type) {
+		return cells
+	}
+	seen := map[[2]int]bool{{x, y}: true}
+	for i := 0; i < len(cells); i++ {
+		c := cells[i]
+		t := w.Grid[c[1]][c[0]].Type
+		for d := 0; d < 4; d++ {
+			if sockets[t][d] < 2 {
+				continue
+			}
+			nx, ny, ok := w.Topology.Step(c[0], c[1], d, w.Width, w.Height)
+			if n := [2]int{nx, ny}; ok && !seen[n] {
+				seen[n] = true
+				cells = append(cells, n)
+			}
+		}
+	}
+	return cells
+}
+
+// frontage is the road cells a building at (x, y) is reached from: those
+// next to it, or, for a building inside its block, those along the block.
+func (w *WFC) frontage(x, y int) [][2]int {
+	var roads [][2]int
+	seen := map[[2]int]bool{}
+	add := func(nx, ny int) {
+		if c := [2]int{nx, ny}; isRoad(w.Grid[ny][nx].Type) && !seen[c] {
+			seen[c] = true
+			roads = append(roads, c)
+		}
+	}
+	for _, c := range w.lot(x, y) {
+		w.neighbors(c[0], c[1], add)
+	}
+	if len(roads) > 0 {
+		return roads
+	}
+
+	inside := map[[2]int]bool{{x, y}: true}
+	stack := [][2]int{{x, y}}
+	for len(stack) > 0 {
+		c := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		w.neighbors(c[0], c[1], func(nx, ny int) {
+			n := [2]int{nx, ny}
+			switch {
+			case isRoad(w.Grid[ny][nx].Type):
+				add(nx, ny)
+			case !inside[n]:
+				inside[n] = true
+				stack = append(stack, n)
+			}
+		})
+	}
+	return roads
+}
+
+// Route finds the shortest way along the roads from the building at
+// (ax, ay) to the one at (bx, by), following each tile's sockets. Among
+// routes of the same length it takes the one with the fewest turns. ok is
+// false when no road joins them.
+func (w *WFC) Route(ax, ay, bx, by int) (r Route, ok bool) {
+	cells, ok := w.path(w.frontage(ax, ay), w.frontage(bx, by))
+	if !ok {
+		return Route{}, false
+	}
+	r.Cells = cells
+	r.Length = len(cells) - 1
+	last := -1
+	for i := 1; i < len(cells); i++ {
+		d := w.dirTo(cells[i-1], cells[i])
+		if last >= 0 && d != last {
+			r.Turns++
+		}
+		last = d
+	}
+	return r, true
+}
+
+// dirTo is the direction of the step from a to its neighbor b.
+func (w *WFC) dirTo(a, b [2]int) int {
+	for d := 0; d < w.Topology.Dirs(); d++ {
+		if nx, ny, ok := w.Topology.Step(a[0], a[1], d, w.Width, w.Height); ok && nx == b[0] && ny == b[1] {
+			return d
+		}
+	}
+	return -1
+}
+
+// path runs a breadth-first search along linked road tiles from any of
+// from to any of to. States are a cell and the direction it was entered
+// in, so ties in length are broken by the fewest turns.
+func (w *WFC) path(from, to [][2]int) ([][2]int, bool) {
+	dirs := w.Topology.Dirs()
+	// State s is cell*(dirs+1) + entry direction + 1; 0 means a start cell
+	state := func(x, y, dir int) int { return (y*w.Width+x)*(dirs+1) + dir + 1 }
+	size := w.Width * w.Height * (dirs + 1)
+	dist, turns, prev := make([]int, size), make([]int, size), make([]int, size)
+	for i := range dist {
+		dist[i], prev[i] = -1, -1
+	}
+	goal := make([]bool, w.Width*w.Height)
+	for _, c := range to {
+		goal[c[1]*w.Width+c[0]] = true
+	}
+
+	var queue []int
+	for _, c := range from {
+		s := state(c[0], c[1], -1)
+		if dist[s] < 0 {
+			dist[s] = 0
+			queue = append(queue, s)
+		}
+	}
+	// best is the goal state with the fewest turns so far. The goal can be
+	// entered from several directions, so the rest of its level is searched
+	// before settling on one.
+	best := -1
+	for k := 0; k < len(queue); k++ {
+		s := queue[k]
+		if best >= 0 && dist[s] > dist[best] {
+			break
+		}
+		cell, in := s/(dirs+1), s%(dirs+1)-1
+		x, y := cell%w.Width, cell/w.Width
+		if goal[cell] {
+			if best < 0 || turns[s] < turns[best] {
+				best = s
+			}
+			continue
+		}
+		t := w.Grid[y][x].Type
+		for d := 0; d < dirs; d++ {
+			if !w.road(t, d) {
+				continue
+			}
+			nx, ny, ok := w.Topology.Step(x, y, d, w.Width, w.Height)
+			if !ok || !w.road(w.Grid[ny][nx].Type, w.Topology.Opposite(d)) {
+				continue
+			}
+			n := state(nx, ny, d)
+			turn := turns[s]
+			if in >= 0 && d != in {
+				turn++
+			}
+			switch {
+			case dist[n] < 0:
+				dist[n], turns[n], prev[n] = dist[s]+1, turn, s
+				queue = append(queue, n)
+			case dist[n] == dist[s]+1 && turn < turns[n]:
+				turns[n], prev[n] = turn, s
+			}
+		}
+	}
+	if best < 0 {
+		return nil, false
+	}
+	var cells [][2]int
+	for s := best; s >= 0; s = prev[s] {
+		c := s / (dirs + 1)
+		cells = append(cells, [2]int{c % w.Width, c / w.Width})
+	}
+	for i, j := 0, len(cells)-1; i < j; i, j = i+1, j-1 {
+		cells[i], cells[j] = cells[j], cells[i]
+	}
+	return cells, true
+}
+
+var (
+	routeBg    = lipgloss.Color("90")
+	endpointBg = lipgloss.Color("201")
+)
+
+// Route finder steps: pick a start, pick a destination, show the route.
+const (
+	routeStart = iota
+	routeDest
+	routeShown
+)
+
+// routeFinder is the state of the route finder mode, toggled with F.
+type routeFinder struct {
+	on       bool
+	step     int
+	from, to [2]int
+	route    map[[2]int]bool
+	note     string
+}
+
+// cursorCell is the grid cell under the cursor.
+func (m Model) cursorCell() (x, y int) {
+	return (m.curX + m.rollX) % m.width, (m.curY + m.rollY) % m.height
+}
+
+// markRoute marks the building under the cursor as the start or the
+// destination, and finds the route once both are set.
+func (m *Model) markRoute() {
+	rf := &m.finder
+	if !m.done {
+		rf.note = "Wait for the city to finish before planning a route."
+		return
+	}
+	x, y := m.cursorCell()
+	if !isBuilding(m.wfc.Grid[y][x].Type) {
+		rf.note = fmt.Sprintf("Cell %d,%d is %s, not a building. Pick a building, shop, factory, tower or mall.", x, y, m.wfc.Grid[y][x].Type)
+		return
+	}
+	switch rf.step {
+	case routeStart, routeShown:
+		rf.from, rf.route, rf.step = [2]int{x, y}, nil, routeDest
+		rf.note = fmt.Sprintf("Start: %s at %d,%d. Pick a destination.", m.wfc.Grid[y][x].Type, x, y)
+	case routeDest:
+		rf.to, rf.step = [2]int{x, y}, routeShown
+		r, ok := m.wfc.Route(rf.from[0], rf.from[1], x, y)
+		if !ok {
+			rf.note = fmt.Sprintf("Destination %d,%d is unreachable from %d,%d: no road joins them.", x, y, rf.from[0], rf.from[1])
+			return
+		}
+		rf.route = map[[2]int]bool{}
+		for _, c := range r.Cells {
+			rf.route[c] = true
+		}
+		rf.note = fmt.Sprintf("Route %d,%d to %d,%d: length %d, %d turns. [Enter] picks a new start.", rf.from[0], rf.from[1], x, y, r.Length, r.Turns)
+	}
+}
+
+// routeMark is style with the route finder highlight of (x, y), if any.
+func (m Model) routeMark(style lipgloss.Style, x, y int) lipgloss.Style {
+	rf := m.finder
+	if !rf.on {
+		return style
+	}
+	c := [2]int{x, y}
+	switch {
+	case rf.step != routeStart && c == rf.from, rf.step == routeShown && c == rf.to:
+		return style.Background(endpointBg)
+	case rf.route[c]:
+		return style.Background(routeBg)
+	}
+	return style
+}
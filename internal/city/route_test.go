@@ -0,0 +1,75 @@
+package city
+
+import "testing"
+
+func TestPath(t *testing.T) {
+	tests := []struct {
+		name          string
+		rows          []string
+		from, to      [2]int
+		ok            bool
+		length, turns int
+	}{
+		{"straight", []string{"╬╬╬╬"}, [2]int{0, 0}, [2]int{3, 0}, true, 3, 0},
+		{"already there", []string{"╬╬╬╬"}, [2]int{1, 0}, [2]int{1, 0}, true, 0, 0},
+		{"short way round", []string{"╔═╗", "║ ║", "╚═╝"}, [2]int{0, 1}, [2]int{2, 0}, true, 3, 1},
+		// Left, left, down beats left, down, left, which is found first
+		{"fewest turns", []string{"╬╬╬", "╬╬ ", "╬╬╬"}, [2]int{2, 0}, [2]int{0, 1}, true, 3, 1},
+		{"sockets not linked", []string{"═║═"}, [2]int{0, 0}, [2]int{2, 0}, false, 0, 0},
+		{"gap", []string{"══ ══"}, [2]int{0, 0}, [2]int{4, 0}, false, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := sketch(t, tt.rows...)
+			cells, ok := w.path([][2]int{tt.from}, [][2]int{tt.to})
+			if ok != tt.ok {
+				t.Fatalf("ok = %v, want %v", ok, tt.ok)
+			}
+			if !ok {
+				return
+			}
+			if cells[0] != tt.from || cells[len(cells)-1] != tt.to {
+				t.Errorf("route %v does not run from %v to %v", cells, tt.from, tt.to)
+			}
+			turns := 0
+			for i := 2; i < len(cells); i++ {
+				if w.dirTo(cells[i-2], cells[i-1]) != w.dirTo(cells[i-1], cells[i]) {
+					turns++
+				}
+			}
+			if len(cells)-1 != tt.length || turns != tt.turns {
+				t.Errorf("route %v: length %d with %d turns, want %d with %d", cells, len(cells)-1, turns, tt.length, tt.turns)
+			}
+		})
+	}
+}
+
+func TestRoute(t *testing.T) {
+	w := sketch(t,
+		"█═╦═█",
+		"  ║  ",
+		"  █  ",
+	)
+	tests := []struct {
+		name                  string
+		ax, ay, bx, by        int
+		ok                    bool
+		length, turns, ncells int
+	}{
+		{"along the street", 0, 0, 4, 0, true, 2, 0, 3},
+		{"round the corner", 0, 0, 2, 2, true, 2, 1, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, ok := w.Route(tt.ax, tt.ay, tt.bx, tt.by)
+			if ok != tt.ok || r.Length != tt.length || r.Turns != tt.turns || len(r.Cells) != tt.ncells {
+				t.Errorf("Route = %+v, %v, want length %d, %d turns over %d cells", r, ok, tt.length, tt.turns, tt.ncells)
+			}
+		})
+	}
+
+	cut := sketch(t, "█═█ █═█")
+	if r, ok := cut.Route(0, 0, 6, 0); ok {
+		t.Errorf("route %+v across a gap in the road", r)
+	}
+}
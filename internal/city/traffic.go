@@ -0,0 +1,323 @@
+package city
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Traffic is a simulation of vehicles driving between the homes and shops
+// of a finished city. Each step every vehicle tries to move one road cell
+// along its route.
+type Traffic struct {
+	w   *WFC
+	rng *rand.Rand
+
+	homes, shops [][2]int
+	junctions    [][]bool
+	caps         [][]int // Vehicles each cell holds, see capacity
+	Fleet        int     // Vehicles the simulation keeps on the roads
+	Vehicles     []*Vehicle
+
+	// Per cell: vehicles on it now, and its congestion, a running
+	// average of how full it is, counting vehicles stuck on it twice.
+	Load [][]int
+	Heat [][]float64
+	// lanes counts the vehicles on each cell by the direction they are
+	// heading, lanes[y][x*dirs+d]
+	lanes [][]int
+
+	Ticks      int
+	Trips      int // Vehicles that reached their destination
+	Delay      int // Ticks those vehicles spent waiting
+	GaveUp     int // Vehicles that waited longer than maxWait and left
+	Unroutable int // Trips that could not be planned, no road joining the ends
+	Waiting    int // Vehicles that could not move on the last step
+}
+
+// Vehicle is one trip in progress.
+type Vehicle struct {
+	Route  [][2]int
+	Lanes  []int // Direction it heads in on each cell of Route
+	At     int   // Index of its cell in Route
+	Waited int   // Ticks waited since it last moved
+	Delay  int   // Ticks waited over the whole trip
+}
+
+const (
+	// laneCap is how many vehicles a road cell holds in each direction.
+	// Junctions let one vehicle in per step.
+	laneCap = 1
+	// maxWait is how long a vehicle sits in a jam before it gives up.
+	maxWait = 100
+	// spawnPerTick is how many vehicles can join the roads per step.
+	spawnPerTick = 4
+	// heatDecay is how much of a cell's congestion carries over a step.
+	heatDecay = 0.95
+)
+
+// NewTraffic starts a simulation of fleet vehicles on the roads of a
+// finished city. The roads start empty and fill over the first steps.
+func NewTraffic(w *WFC, seed int64, fleet int) *Traffic {
+	t := &Traffic{w: w, rng: rand.New(rand.NewSource(seed)), Fleet: fleet}
+	t.Load = make([][]int, w.Height)
+	t.Heat = make([][]float64, w.Height)
+	t.junctions = make([][]bool, w.Height)
+	t.lanes = make([][]int, w.Height)
+	t.caps = make([][]int, w.Height)
+	for y := 0; y < w.Height; y++ {
+		t.caps[y] = make([]int, w.Width)
+		t.Load[y] = make([]int, w.Width)
+		t.lanes[y] = make([]int, w.Width*w.Topology.Dirs())
+		t.Heat[y] = make([]float64, w.Width)
+		t.junctions[y] = make([]bool, w.Width)
+		for x := 0; x < w.Width; x++ {
+			links := len(w.exits(x, y))
+			t.junctions[y][x] = links >= 3
+			if links > 0 {
+				t.caps[y][x] = laneCap * max(2, links)
+			}
+			switch w.Grid[y][x].Type {
+			case Building:
+				t.homes = append(t.homes, [2]int{x, y})
+			case Commercial:
+				t.shops = append(t.shops, [2]int{x, y})
+			}
+		}
+	}
+	return t
+}
+
+// junction reports whether (x, y) is a crossing or T-junction, where
+// vehicles enter one at a time.
+func (t *Traffic) junction(x, y int) bool {
+	return t.junctions[y][x]
+}
+
+// capacity is how many vehicles fit on (x, y) at once, one per lane: two
+// on a road, one more for each further way out of a junction.
+func (t *Traffic) capacity(x, y int) int {
+	return t.caps[y][x]
+}
+
+// fits reports whether v's lane at step i of its route has room.
+func (t *Traffic) fits(v *Vehicle, i int) bool {
+	c := v.Route[i]
+	return t.lanes[c[1]][c[0]*t.w.Topology.Dirs()+v.Lanes[i]] < laneCap
+}
+
+// move adds n vehicles, 1 or -1, to v's lane at step i of its route.
+func (t *Traffic) move(v *Vehicle, i, n int) {
+	c := v.Route[i]
+	t.Load[c[1]][c[0]] += n
+	t.lanes[c[1]][c[0]*t.w.Topology.Dirs()+v.Lanes[i]] += n
+}
+
+// minor reports whether v is about to enter a junction from a side road:
+// one whose opposite side the junction does not lead on to. At a T-junction
+// that is the stem, which yields to traffic along the bar.
+func (t *Traffic) minor(v *Vehicle) bool {
+	if v.At+1 >= len(v.Route) {
+		return false
+	}
+	next := v.Route[v.At+1]
+	if !t.junction(next[0], next[1]) {
+		return false
+	}
+	return !t.w.road(t.w.Grid[next[1]][next[0]].Type, t.w.dirTo(v.Route[v.At], next))
+}
+
+// spawn puts up to spawnPerTick new vehicles on the roads, each driving
+// from a random home to a random shop or back.
+func (t *Traffic) spawn() {
+	if len(t.homes) == 0 || len(t.shops) == 0 {
+		return
+	}
+	for i := 0; i < spawnPerTick && len(t.Vehicles) < t.Fleet; i++ {
+		a, b := t.homes[t.rng.Intn(len(t.homes))], t.shops[t.rng.Intn(len(t.shops))]
+		if t.rng.Intn(2) == 0 {
+			a, b = b, a
+		}
+		r, ok := t.w.Route(a[0], a[1], b[0], b[1])
+		if !ok {
+			t.Unroutable++
+			continue
+		}
+		v := &Vehicle{Route: r.Cells, Lanes: make([]int, len(r.Cells))}
+		for j := 1; j < len(r.Cells); j++ {
+			v.Lanes[j] = t.w.dirTo(r.Cells[j-1], r.Cells[j])
+		}
+		if len(r.Cells) > 1 {
+			v.Lanes[0] = v.Lanes[1]
+		}
+		if !t.fits(v, 0) {
+			continue
+		}
+		t.move(v, 0, 1)
+		t.Vehicles = append(t.Vehicles, v)
+	}
+}
+
+// Step moves the simulation one tick on. Vehicles on the main road go
+// first, then those that have waited longest, so side roads yield at
+// junctions and queues drain in turn.
+func (t *Traffic) Step() {
+	t.Ticks++
+	t.spawn()
+
+	sort.SliceStable(t.Vehicles, func(i, j int) bool {
+		a, b := t.Vehicles[i], t.Vehicles[j]
+		if ma, mb := t.minor(a), t.minor(b); ma != mb {
+			return mb
+		}
+		return a.Waited > b.Waited
+	})
+	entered := map[[2]int]bool{} // Junctions a vehicle entered this tick
+	stuck := make(map[[2]int]int)
+	kept := t.Vehicles[:0]
+	t.Waiting = 0
+	for _, v := range t.Vehicles {
+		cur := v.Route[v.At]
+		if v.At == len(v.Route)-1 {
+			t.move(v, v.At, -1)
+			t.Trips++
+			t.Delay += v.Delay
+			continue
+		}
+		next := v.Route[v.At+1]
+		if !t.fits(v, v.At+1) || entered[next] {
+			v.Waited++
+			v.Delay++
+			t.Waiting++
+			if v.Waited > maxWait {
+				t.move(v, v.At, -1)
+				t.GaveUp++
+				continue
+			}
+			stuck[cur]++
+			kept = append(kept, v)
+			continue
+		}
+		if t.junction(next[0], next[1]) {
+			entered[next] = true
+		}
+		t.move(v, v.At, -1)
+		t.move(v, v.At+1, 1)
+		v.At++
+		v.Waited = 0
+		kept = append(kept, v)
+	}
+	clear(t.Vehicles[len(kept):])
+	t.Vehicles = kept
+
+	for y := range t.Heat {
+		for x := range t.Heat[y] {
+			if t.capacity(x, y) == 0 {
+				continue
+			}
+			load := float64(t.Load[y][x]+stuck[[2]int{x, y}]) / float64(t.capacity(x, y))
+			t.Heat[y][x] = t.Heat[y][x]*heatDecay + min(load, 1)*(1-heatDecay)
+		}
+	}
+}
+
+// Busiest is the road cell with the highest congestion, and that
+// congestion from 0 to 1.
+func (t *Traffic) Busiest() (x, y int, heat float64) {
+	for cy := range t.Heat {
+		for cx, h := range t.Heat[cy] {
+			if h > heat {
+				x, y, heat = cx, cy, h
+			}
+		}
+	}
+	return x, y, heat
+}
+
+// trafficLevels are the fleet sizes G cycles through, in vehicles per
+// hundred road cells.
+var trafficLevels = []int{5, 15, 30, 60}
+
+const defaultTrafficLevel = 1
+
+// trafficEvery is how many ticks pass between simulation steps at each of
+// the playback speeds.
+var trafficEvery = []int{20, 8, 4, 2}
+
+var vehicleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("51")).Bold(true)
+
+// fleet is the number of vehicles for the current traffic level.
+func (m Model) fleet() int {
+	roads := 0
+	for _, row := range m.wfc.Grid {
+		for _, tile := range row {
+			if isRoad(tile.Type) {
+				roads++
+			}
+		}
+	}
+	return max(1, roads*trafficLevels[m.trafficLevel]/100)
+}
+
+// toggleTraffic starts or stops the traffic simulation on a finished city.
+func (m *Model) toggleTraffic() {
+	if m.traffic != nil {
+		m.traffic = nil
+		return
+	}
+	if !m.done {
+		m.notice = "Traffic starts once the city is finished."
+		return
+	}
+	m.traffic = NewTraffic(m.wfc, m.wfc.Seed, m.fleet())
+	if len(m.traffic.homes) == 0 || len(m.traffic.shops) == 0 {
+		m.notice = "This city has no homes or no shops to drive between."
+	}
+}
+
+// vehicleCell is the traffic rendering of road cell (x, y) on the live
+// grid, with ok false when no vehicle is on it.
+func (m Model) vehicleCell(x, y int, glyph func(string) string) (cell string, ok bool) {
+	t := m.traffic
+	if t == nil || !m.player().Live() || t.Load[y][x] == 0 {
+		return "", false
+	}
+	g := "●"
+	if t.Load[y][x] > 1 {
+		g = "◉"
+	}
+	return vehicleStyle.Background(heatRamp[int(t.Heat[y][x]*float64(len(heatRamp)-1))]).Render(glyph(g)), true
+}
+
+// congestionCell is the congestion overlay rendering of grid cell (x, y):
+// roads on the heat ramp by congestion, other cells dimmed.
+func (m Model) congestionCell(x, y int, glyph func(string) string) string {
+	tile := m.wfc.Record().Grid()[y][x]
+	if !tile.Collapsed {
+		return dimStyle.Render(glyph("?"))
+	}
+	if m.traffic == nil || !isRoad(tile.Type) {
+		return dimStyle.Render(glyph(tile.Type.Glyph()))
+	}
+	i := int(m.traffic.Heat[y][x] * float64(len(heatRamp)-1))
+	return heatGlyph.Background(heatRamp[i]).Render(glyph(tile.Type.Glyph()))
+}
+
+var dimStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("238"))
+
+// viewTraffic is the status line of the traffic simulation.
+func (m Model) viewTraffic() string {
+	t := m.traffic
+	if t == nil {
+		return fmt.Sprintf("  Traffic: off | [T] Start  [G] Level: %d per 100 roads", trafficLevels[m.trafficLevel])
+	}
+	delay := 0.0
+	if t.Trips > 0 {
+		delay = float64(t.Delay) / float64(t.Trips)
+	}
+	bx, by, heat := t.Busiest()
+	return fmt.Sprintf("  Traffic: tick %d | %d/%d vehicles, %d waiting | %d trips, %.1f ticks delay each | %d gave up, %d unroutable | busiest %d,%d at %.0f%% | [T] Stop  [G] Level: %d per 100 roads",
+		t.Ticks, len(t.Vehicles), t.Fleet, t.Waiting, t.Trips, delay, t.GaveUp, t.Unroutable, bx, by, heat*100, trafficLevels[m.trafficLevel])
+}
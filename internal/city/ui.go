@@ -1,22 +1,39 @@
 package city
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"atlas.games/internal/mapio"
+	"atlas.games/internal/registry"
+	"atlas.games/internal/solver"
 )
 
 var (
-	roadStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("244"))
-	buildingStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Bold(true)
-	parkStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color("34"))
-	commercialStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("220")).Bold(true)
-	waterStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("33"))
+	roadStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[RoadV].Color))
+	buildingStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[Building].Color)).Bold(true)
+	parkStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[Park].Color))
+	commercialStyle = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[Commercial].Color)).Bold(true)
+	waterStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[Water].Color))
+	factoryStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[Factory].Color))
+	towerStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[TowerTL].Color)).Bold(true)
+	bridgeStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[BridgeV].Color)).Bold(true)
+	quayStyle       = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[QuayN].Color))
+	shoreStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color(tileInfo[ShoreNE].Color))
 	titleStyle      = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true)
 )
 
+// screenWidth and screenHeight are the size of the map area in terminal
+// cells. Hex cells take two columns, plus one for the shifted odd rows.
+const screenWidth, screenHeight = 200, 60
+
+// rollStep is how far an arrow key rolls a wrapped city. It is even so hex
+// rows keep their offsets.
+const rollStep = 4
+
 type tickMsg time.Time
 
 type Model struct {
@@ -25,15 +42,93 @@ type Model struct {
 	width       int
 	height      int
 	showingHelp bool
+	notice      string // Result of the last export
+
+	// A wrapped city can be rolled with the arrow keys, showing from
+	// column rollX and row rollY, to check that it tiles.
+	topo         solver.Topology
+	rollX, rollY int
+
+	// Timeline playback, see playback.go. speed indexes speeds.
+	paused bool
+	speed  int
+
+	// Heat-map overlay and inspector cursor, in grid cells on screen; see
+	// overlay.go
+	overlay    int
+	curX, curY int
+
+	// exits indexes exitPresets, the edges the road network must leave by
+	exits int
+
+	// Route finder mode, see route.go. It shares the inspector cursor.
+	finder routeFinder
+
+	// Traffic simulation on the finished city, see traffic.go. ticks
+	// counts ticks between its steps; trafficLevel indexes trafficLevels.
+	traffic      *Traffic
+	ticks        int
+	trafficLevel int
+
+	// Metrics sidebar, see metrics.go. report is measured once the city
+	// is done.
+	metrics bool
+	report  *Report
+}
+
+// exitPresets are the required exits O cycles through.
+var exitPresets = [][]int{
+	nil,
+	{solver.Up, solver.Down},
+	{solver.Right, solver.Left},
+	{solver.Up, solver.Right, solver.Down, solver.Left},
+}
+
+func init() {
+	registry.Register(registry.Game{
+		ID:          "wfc-city",
+		Title:       "WFC City Generator",
+		Description: "Urban layout generation using Wave Function Collapse.",
+		Category:    registry.Generators,
+		Order:       2,
+		New:         func(seed int64) tea.Model { return NewModel(seed) },
+	})
+}
+
+func NewModel(seed int64) Model {
+	m := Model{topo: solver.Square{}, speed: defaultSpeed, trafficLevel: defaultTrafficLevel}
+	m.reset(seed)
+	return m
+}
+
+// reset starts a new city with seed on the current topology.
+func (m *Model) reset(seed int64) {
+	m.width, m.height = screenWidth, screenHeight
+	if m.hex() {
+		m.width = (screenWidth - 1) / 2
+	}
+	m.wfc = NewConnectedWFC(m.width, m.height, seed, m.topo, exitPresets[m.exits])
+	m.rollX, m.rollY = 0, 0
+	m.curX, m.curY = min(m.curX, m.width-1), min(m.curY, m.height-1)
+	m.finder = routeFinder{on: m.finder.on}
+	m.traffic = nil
+	m.report = nil
+	m.done = false
 }
 
-func NewModel() Model {
-	w, h := 200, 60
-	return Model{
-		wfc:    NewWFC(w, h),
-		width:  w,
-		height: h,
+func (m Model) hex() bool {
+	_, ok := m.topo.(solver.Hex)
+	return ok
+}
+
+func (m Model) wrapped() bool {
+	switch t := m.topo.(type) {
+	case solver.Square:
+		return t.Wrap
+	case solver.Hex:
+		return t.Wrap
 	}
+	return false
 }
 
 func (m Model) Init() tea.Cmd {
@@ -47,20 +142,104 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "q", "ctrl+c", "esc":
 			return m, tea.Quit
 		case "r":
-			m.wfc = NewWFC(m.width, m.height)
-			m.done = false
+			m.reset(time.Now().UnixNano())
 			m.showingHelp = false
+			m.notice = ""
+			return m, tick()
+		case "x", "a":
+			// Same seed, so the layouts can be compared side by side
+			hex, wrap := m.hex(), m.wrapped()
+			if msg.String() == "x" {
+				hex = !hex
+			} else {
+				wrap = !wrap
+			}
+			m.topo = solver.Square{Wrap: wrap}
+			if hex {
+				m.topo = solver.Hex{Wrap: wrap}
+			}
+			m.reset(m.wfc.Seed)
+			return m, tick()
+		case "o":
+			m.exits = (m.exits + 1) % len(exitPresets)
+			m.reset(m.wfc.Seed)
 			return m, tick()
+		case "v":
+			m.overlay = (m.overlay + 1) % overlayModes
+			return m, nil
+		case "f":
+			m.finder = routeFinder{on: !m.finder.on, note: "Move to a building and press [Enter] to mark the start."}
+			return m, nil
+		case "t":
+			m.toggleTraffic()
+			if m.traffic != nil && !m.paused {
+				return m, tick()
+			}
+			return m, nil
+		case "m":
+			m.metrics = !m.metrics
+			m.measure()
+			return m, nil
+		case "g":
+			m.trafficLevel = (m.trafficLevel + 1) % len(trafficLevels)
+			if m.traffic != nil {
+				m.traffic.Fleet = m.fleet()
+			}
+			return m, nil
+		case "enter":
+			if m.finder.on {
+				m.markRoute()
+			}
+			return m, nil
+		case "up", "down", "left", "right":
+			if m.overlay != overlayOff || m.finder.on {
+				m.moveCursor(msg.String())
+				return m, nil
+			}
+			if !m.wrapped() {
+				return m, nil
+			}
+			switch msg.String() {
+			case "up":
+				m.rollY -= rollStep
+			case "down":
+				m.rollY += rollStep
+			case "left":
+				m.rollX -= rollStep
+			case "right":
+				m.rollX += rollStep
+			}
+			m.rollX = (m.rollX%m.width + m.width) % m.width
+			m.rollY = (m.rollY%m.height + m.height) % m.height
+			return m, nil
+		case "e":
+			m.notice = export(m.wfc.Map(), "png", "svg")
+			if m.done {
+				m.notice += exportGraph(m.wfc)
+			}
+			return m, nil
 		case "h":
 			m.showingHelp = !m.showingHelp
 			return m, nil
+		default:
+			if cmd, ok := m.updatePlayback(msg.String()); ok {
+				return m, cmd
+			}
 		}
 	case tickMsg:
-		if !m.done && !m.showingHelp {
-			for i := 0; i < 50; i++ {
-				m.done = m.wfc.Step()
+		if !m.done && !m.showingHelp && !m.paused {
+			for i := 0; i < speeds[m.speed]; i++ {
+				m.done = m.advance()
 				if m.done { break }
 			}
+			m.measure()
+			return m, tick()
+		}
+		if m.traffic != nil && !m.showingHelp && !m.paused {
+			m.ticks++
+			if m.ticks%trafficEvery[m.speed] == 0 {
+				m.traffic.Step()
+			}
 			return m, tick()
 		}
 	}
@@ -75,50 +254,151 @@ func (m Model) View() string {
 		sb.WriteString("  " + buildingStyle.Render("█ Building  ") + ": Residential zones (White Blocks).\n")
 		sb.WriteString("  " + commercialStyle.Render("S Commercial") + ": Business districts (Yellow Shops).\n")
 		sb.WriteString("  " + parkStyle.Render("♣ Park      ") + ": Green spaces for the citizens.\n")
-		sb.WriteString("  " + waterStyle.Render("~ Water     ") + ": Fountains, lakes, or pools.\n\n")
-		sb.WriteString("  [R] Reset City  [H] Close Documentation  [Q] Exit to Launcher\n")
+		sb.WriteString("  " + waterStyle.Render("~ Water     ") + ": Fountains, lakes, or pools.\n")
+		sb.WriteString("  " + factoryStyle.Render("▓ Factory   ") + ": Works and warehouses in the industrial zones.\n")
+		sb.WriteString("  " + bridgeStyle.Render("╫╪ Bridge   ") + ": Carries a road over water. " + quayStyle.Render("▔▕▁▏ Quay") + " and " + shoreStyle.Render("◥◢◣◤ Shore") + ": Line the water.\n")
+		sb.WriteString("  " + towerStyle.Render("▛▜ Tower    ") + ": 2x2 high-rises, mostly downtown. " + commercialStyle.Render("┏━┓ Mall") + ": 3x2 shopping centers.\n\n")
+		sb.WriteString("  Zoning      : Downtown, residential, industrial and waterfront districts scale the tile weights;\n")
+		sb.WriteString("                small blocks that are mostly green become whole parks. [V] shows the zones.\n")
+		sb.WriteString("  " + roadStyle.Render("═╱╲<>    Hex     ") + ": [X] switches to a hex grid, where roads leave through six sides.\n")
+		sb.WriteString("  Wrap        : [A] joins opposite edges so the city tiles; the arrows roll it.\n")
+		sb.WriteString("  Timeline    : [Space] pauses, [,] and [.] step, [[] and []] scrub. The cell just collapsed\n")
+		sb.WriteString("                is " + lipgloss.NewStyle().Background(observedBg).Render("highlighted") + " and neighbors whose possibilities shrank are " + lipgloss.NewStyle().Background(shrunkBg).Render("shaded") + ".\n")
+		sb.WriteString("  Network     : Finished cities keep one connected road network; stray loops are cleared into blocks\n")
+		sb.WriteString("                and roads never run off the map. [O] cycles exits the network must leave by.\n")
+		sb.WriteString("  Heat map    : [V] colors undecided cells by possibilities left, then by entropy, from " + heat(1, 0) + " to " + heat(9, 1) + ",\n")
+		sb.WriteString("                then tints every cell by zone, then shows road congestion while traffic runs.\n")
+		sb.WriteString("                The arrows move a cursor; the inspector lists its candidate tiles and weights.\n")
+		sb.WriteString("  Routes      : [F] opens the route finder. Mark a start and a destination building with [Enter]; the\n")
+		sb.WriteString("                shortest way along the road sockets is " + lipgloss.NewStyle().Background(routeBg).Render("highlighted") + " with its length and turns.\n")
+		sb.WriteString("  Traffic     : [T] sends " + vehicleStyle.Render("●") + " vehicles between homes and shops on a finished city. Roads have a lane each way\n")
+		sb.WriteString("                and junctions let one in per step; side roads yield to the main road. [G] sets the level, [-/+] the speed.\n")
+		sb.WriteString("  Metrics     : [M] shows a sidebar on a finished city: land use, the walks from homes to the nearest\n")
+		sb.WriteString("                park and shop, dead-end roads and a livability score out of 100.\n\n")
+		sb.WriteString("  [R] Reset City  [X] Square/Hex  [A] Wrap Edges  [O] Exits  [V] Heat Map  [F] Route Finder  [T] Traffic  [G] Traffic Level  [M] Metrics  [E] Export PNG+SVG+Road Graph  [H] Close Documentation  [Q] Exit to Launcher\n")
 		return sb.String()
 	}
 
 	var sb strings.Builder
 	sb.WriteString("  " + titleStyle.Render(" ATLAS CITY ENGINE - CITY GENERATOR ") + "\n")
 
+	glyph := func(g string) string { return g }
+	if m.hex() {
+		glyph = mapio.HexGlyph
+	}
+	p := m.player()
+	grid, marks := m.wfc.Record().Grid(), p.Marks()
+	var rows strings.Builder
 	for y := 0; y < m.height; y++ {
-		sb.WriteString("  ")
+		rows.WriteString("  ")
+		if m.hex() && y%2 == 1 {
+			rows.WriteString(" ")
+		}
 		for x := 0; x < m.width; x++ {
-			tile := m.wfc.Grid[y][x]
+			gx, gy := (x+m.rollX)%m.width, (y+m.rollY)%m.height
+			tile := grid[gy][gx]
+			if (m.overlay != overlayOff || m.finder.on) && x == m.curX && y == m.curY {
+				g := "?"
+				if tile.Collapsed {
+					g = tile.Type.Glyph()
+				}
+				rows.WriteString(cursorStyle.Render(glyph(g)))
+				continue
+			}
+			if m.overlay == overlayZones {
+				rows.WriteString(m.zoneCell(gx, gy, glyph))
+				continue
+			}
+			if m.overlay == overlayCongestion {
+				rows.WriteString(m.congestionCell(gx, gy, glyph))
+				continue
+			}
+			if cell, ok := m.overlayCell(gx, gy); ok {
+				if m.hex() {
+					cell += cell
+				}
+				rows.WriteString(cell)
+				continue
+			}
 			if !tile.Collapsed {
-				sb.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("235")).Render("?"))
+				rows.WriteString(marked(lipgloss.NewStyle().Foreground(lipgloss.Color("235")), marks, gx, gy).Render(glyph("?")))
 			} else {
-				char := " "
 				style := roadStyle
 				switch tile.Type {
-				case RoadV: char = "║"
-				case RoadH: char = "═"
-				case RoadTL: char = "╔"
-				case RoadTR: char = "╗"
-				case RoadBL: char = "╚"
-				case RoadBR: char = "╝"
-				case RoadTU: char = "╩"
-				case RoadTD: char = "╦"
-				case RoadTLT: char = "╠"
-				case RoadTRT: char = "╣"
-				case RoadCross: char = "╬"
-				case Building: char = "█"; style = buildingStyle
-				case Commercial: char = "S"; style = commercialStyle
-				case Park: char = "♣"; style = parkStyle
-				case Water: char = "~"; style = waterStyle
+				case Building: style = buildingStyle
+				case Commercial: style = commercialStyle
+				case Park: style = parkStyle
+				case Water: style = waterStyle
+				case Factory: style = factoryStyle
+				case TowerTL, TowerTR, TowerBL, TowerBR: style = towerStyle
+				case MallTL, MallT, MallTR, MallBL, MallB, MallBR: style = commercialStyle
+				case BridgeV, BridgeH: style = bridgeStyle
+				case QuayN, QuayE, QuayS, QuayW: style = quayStyle
+				case ShoreNE, ShoreSE, ShoreSW, ShoreNW: style = shoreStyle
 				}
-				sb.WriteString(style.Render(char))
+				if cell, ok := m.vehicleCell(gx, gy, glyph); ok {
+					rows.WriteString(cell)
+					continue
+				}
+				rows.WriteString(m.routeMark(marked(style, marks, gx, gy), gx, gy).Render(glyph(tile.Type.Glyph())))
 			}
 		}
-		sb.WriteString("\n")
+		rows.WriteString("\n")
+	}
+	if m.metrics && m.report != nil {
+		sb.WriteString(lipgloss.JoinHorizontal(lipgloss.Top, strings.TrimSuffix(rows.String(), "\n"), viewReport(m.report)) + "\n")
+	} else {
+		sb.WriteString(rows.String())
 	}
 
-	sb.WriteString("  [R] Reset City  [H] Help  [Q] Exit to Launcher")
+	roll := ""
+	if m.wrapped() {
+		roll = fmt.Sprintf(" | Roll: %d,%d", m.rollX, m.rollY)
+	}
+	sb.WriteString(fmt.Sprintf("  Seed: %d | Grid: %s%s | Backtracks: %d | Restarts: %d | [R] Reset City  [X] Hex  [A] Wrap  [E] Export  [H] Help  [Q] Exit to Launcher", m.wfc.Seed, solver.Describe(m.topo), roll, m.wfc.Backtracks, m.wfc.Restarts))
+	sb.WriteString("\n" + m.viewNetwork())
+	if m.done {
+		sb.WriteString("\n" + m.viewTraffic())
+	}
+	sb.WriteString("\n" + viewTimeline(p, marks, m.paused, m.speed, func(t int) string { return TileType(t).String() }))
+	if m.finder.on {
+		sb.WriteString("\n  Route finder: " + m.finder.note + " | [Arrows] Move  [Enter] Mark  [F] Close")
+	}
+	if m.overlay != overlayOff {
+		sb.WriteString(fmt.Sprintf("\n  Overlay: %s | [V] Cycle  [Arrows] Move Cursor\n", overlayNames[m.overlay]))
+		sb.WriteString(m.inspect())
+	}
+	if m.notice != "" {
+		sb.WriteString("\n  " + m.notice)
+	}
 	return sb.String()
 }
 
+// export saves the map on screen in the working directory and reports the
+// result for the status line.
+func export(m *mapio.Map, formats ...string) string {
+	names, err := mapio.SaveAs(m, mapio.Options{Scale: mapio.DefaultScale}, formats...)
+	if err != nil {
+		return "Export failed: " + err.Error()
+	}
+	return "Saved " + strings.Join(names, ", ")
+}
+
+// exportGraph saves the road graph of a finished city next to the map
+// export and reports the result for the status line, continuing export's.
+func exportGraph(w *WFC) string {
+	g := w.Graph()
+	var names []string
+	for _, ext := range []string{"json", "graphml"} {
+		name := fmt.Sprintf("city-%d-roads.%s", w.Seed, ext)
+		if err := SaveGraph(name, g); err != nil {
+			return "; road graph failed: " + err.Error()
+		}
+		names = append(names, name)
+	}
+	return ", " + strings.Join(names, ", ")
+}
+
 func tick() tea.Cmd {
 	return tea.Every(time.Millisecond*10, func(t time.Time) tea.Msg {
 		return tickMsg(t)
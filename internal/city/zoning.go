@@ -0,0 +1,211 @@
+package city
+
+import (
+	"atlas.games/internal/solver"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Zone is the district a cell is planned for. It biases the tile weights
+// there, see zoneBias.
+type Zone int
+
+const (
+	Residential Zone = iota
+	Downtown
+	Industrial
+	Waterfront
+	zoneCount
+)
+
+var zoneNames = [zoneCount]string{"residential", "downtown", "industrial", "waterfront"}
+
+func (z Zone) String() string {
+	return zoneNames[z]
+}
+
+var zoneStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("255"))
+
+// zoneBg tints the zones in the zone overlay.
+var zoneBg = [zoneCount]lipgloss.Color{"22", "54", "94", "24"}
+
+// zoneBias scales the weights map per zone, in percent; tiles left out
+// keep their weight. Footprint parts are listed per footprint in
+// footprintBias.
+var zoneBias = [zoneCount]map[TileType]int{
+	Residential: {Building: 200, Park: 250, Commercial: 60, Factory: 5, Water: 50, RoadCross: 50},
+	Downtown:    {Building: 50, Park: 40, Commercial: 300, Factory: 5, Water: 10, RoadCross: 250, RoadTU: 150, RoadTD: 150, RoadTLT: 150, RoadTRT: 150},
+	Industrial:  {Building: 40, Park: 20, Commercial: 50, Factory: 600, Water: 30},
+	Waterfront:  {Building: 60, Park: 200, Commercial: 150, Factory: 20, Water: 600, BridgeV: 300, BridgeH: 300, QuayN: 400, QuayE: 400, QuayS: 400, QuayW: 400},
+}
+
+// footprintBias is zoneBias for the footprints, indexed like footprints:
+// towers crowd downtown, malls sit downtown and among the warehouses.
+var footprintBias = [zoneCount][]int{
+	Residential: {10, 20},
+	Downtown:    {600, 300},
+	Industrial:  {10, 300},
+	Waterfront:  {50, 100},
+}
+
+const (
+	// residentsEvery and industryEvery are how many cells each
+	// residential and industrial district covers.
+	residentsEvery = 1500
+	industryEvery  = 4000
+	// downtownReach makes downtown reach this many times as far as the
+	// other zones.
+	downtownReach = 1.6
+	// parkBlock is the largest block the zoning pass turns into a park,
+	// and parkShare the share of it that must already be park.
+	parkBlock = 40
+	parkShare = 0.3
+)
+
+// site is the center of a district.
+type site struct {
+	x, y  int
+	zone  Zone
+	reach float64
+}
+
+// plan lays out the districts: downtown around the center, waterfront
+// around a random point and residential and industrial districts around
+// the rest, each cell joining the nearest center. It returns the
+// waterfront center.
+func (w *WFC) plan(s *solver.Solver[TileType]) (wx, wy int) {
+	rng := s.Rand()
+	sites := []site{
+		{x: w.Width / 2, y: w.Height / 2, zone: Downtown, reach: downtownReach},
+		{x: rng.Intn(w.Width), y: rng.Intn(w.Height), zone: Waterfront, reach: 1},
+	}
+	area := w.Width * w.Height
+	for i := 0; i < max(1, area/industryEvery); i++ {
+		sites = append(sites, site{x: rng.Intn(w.Width), y: rng.Intn(w.Height), zone: Industrial, reach: 1})
+	}
+	for i := 0; i < max(2, area/residentsEvery); i++ {
+		sites = append(sites, site{x: rng.Intn(w.Width), y: rng.Intn(w.Height), zone: Residential, reach: 1})
+	}
+
+	w.Zones = make([][]Zone, w.Height)
+	for y := range w.Zones {
+		w.Zones[y] = make([]Zone, w.Width)
+		for x := range w.Zones[y] {
+			best := -1.0
+			for _, st := range sites {
+				dx, dy := float64(w.span(x, st.x, w.Width)), float64(w.span(y, st.y, w.Height))
+				d := (dx*dx + dy*dy*4) / (st.reach * st.reach) // Rows are twice as tall as columns
+				if best < 0 || d < best {
+					best, w.Zones[y][x] = d, st.zone
+				}
+			}
+		}
+	}
+	return sites[1].x, sites[1].y
+}
+
+// span is the distance between a and b along an axis of length n, across
+// the seam on a wrapped map.
+func (w *WFC) span(a, b, n int) int {
+	d := a - b
+	if d < 0 {
+		d = -d
+	}
+	if w.wrapped() && n-d < d {
+		d = n - d
+	}
+	return d
+}
+
+// bias is the Rules.Bias of a zoned city.
+func (w *WFC) bias(x, y int, t TileType) int {
+	if w.Zones == nil {
+		return 100
+	}
+	z := w.Zones[y][x]
+	for i, parts := range footprints {
+		for _, p := range parts {
+			if p == t {
+				return footprintBias[z][i]
+			}
+		}
+	}
+	if b, ok := zoneBias[z][t]; ok {
+		return b
+	}
+	return 100
+}
+
+// ZoneAt is the district (x, y) was planned for.
+func (w *WFC) ZoneAt(x, y int) Zone {
+	if w.Zones == nil {
+		return Residential
+	}
+	return w.Zones[y][x]
+}
+
+// plantParks turns small blocks of the kept network that are already
+// largely park, outside downtown and industry, into whole parks. Blocks
+// with a footprint keep their buildings. comp is the road network of each
+// cell, as from components. It returns the number of blocks planted.
+func (w *WFC) plantParks(comp [][]int) int {
+	seen := make([][]bool, w.Height)
+	for y := range seen {
+		seen[y] = make([]bool, w.Width)
+	}
+	n := 0
+	for y := 0; y < w.Height; y++ {
+		for x := 0; x < w.Width; x++ {
+			if seen[y][x] || comp[y][x] >= 0 {
+				continue
+			}
+			var block [][2]int
+			seen[y][x] = true
+			stack := [][2]int{{x, y}}
+			for len(stack) > 0 {
+				c := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				block = append(block, c)
+				w.neighbors(c[0], c[1], func(nx, ny int) {
+					if !seen[ny][nx] && comp[ny][nx] < 0 {
+						seen[ny][nx] = true
+						stack = append(stack, [2]int{nx, ny})
+					}
+				})
+			}
+			if z := w.ZoneAt(x, y); len(block) > parkBlock || z == Downtown || z == Industrial {
+				continue
+			}
+			parks, open := 0, true
+			for _, c := range block {
+				switch t := w.Grid[c[1]][c[0]].Type; {
+				case isPart(t):
+					open = false
+				case t == Park:
+					parks++
+				}
+			}
+			if !open || float64(parks) < float64(len(block))*parkShare {
+				continue
+			}
+			for _, c := range block {
+				// Water and its banks stay, so the park lines the shore
+				if t := w.Grid[c[1]][c[0]].Type; t == Building || t == Commercial || t == Factory {
+					w.Overwrite(c[0], c[1], Park)
+				}
+			}
+			n++
+		}
+	}
+	return n
+}
+
+// zoneCell is the zone overlay rendering of grid cell (x, y): its tile on
+// the zone's tint. glyph widens glyphs for hex cells.
+func (m Model) zoneCell(x, y int, glyph func(string) string) string {
+	tile := m.wfc.Record().Grid()[y][x]
+	g := "?"
+	if tile.Collapsed {
+		g = tile.Type.Glyph()
+	}
+	return zoneStyle.Background(zoneBg[m.wfc.ZoneAt(x, y)]).Render(glyph(g))
+}
@@ -3,7 +3,6 @@ package colony
 import (
 	"math"
 	"math/rand"
-	"time"
 )
 
 type CellType int
@@ -23,6 +22,15 @@ type Ant struct {
 	HasFood  bool
 	Activity string // "digging", "foraging", "returning"
 	TargetX  int    
+
+	// Cached way home for a carrier, see findNextStepHome: the cells still
+	// to go, the cost of the way on from each, and the same cells as a set
+	// for set to check changes against. pathCost is the cost from where
+	// the ant stands.
+	path     [][2]int
+	rest     []int
+	onPath   map[[2]int]bool
+	pathCost int
 }
 
 type Spider struct {
@@ -36,14 +44,17 @@ type Colony struct {
 	Grid          [][]CellType
 	Ants          []*Ant
 	Spiders       []*Spider
+	Seed          int64
+	rng           *rand.Rand
 }
 
-func NewColony(w, h int) *Colony {
-	rand.Seed(time.Now().UnixNano())
+func NewColony(w, h int, seed int64) *Colony {
 	c := &Colony{
 		Width:  w,
 		Height: h,
 		Grid:   make([][]CellType, h),
+		Seed:   seed,
+		rng:    rand.New(rand.NewSource(seed)),
 	}
 
 	for y := 0; y < h; y++ {
@@ -71,23 +82,23 @@ func NewColony(w, h int) *Colony {
 		c.Ants = append(c.Ants, &Ant{
 			X: cx, Y: cy, 
 			Activity: activity,
-			TargetX: rand.Intn(w),
+			TargetX: c.rng.Intn(w),
 		})
 	}
 
 	for i := 0; i < 20; i++ {
-		c.Grid[rand.Intn(4)][rand.Intn(w)] = Food
+		c.Grid[c.rng.Intn(4)][c.rng.Intn(w)] = Food
 	}
 
 	for i := 0; i < 50; i++ {
-		fx, fy := rand.Intn(w), 6+rand.Intn(h-7)
+		fx, fy := c.rng.Intn(w), 6+c.rng.Intn(h-7)
 		if c.Grid[fy][fx] == Dirt {
 			c.Grid[fy][fx] = Food
 		}
 	}
 
 	for i := 0; i < 15; i++ {
-		sx, sy := rand.Intn(w), 4+rand.Intn(h-5)
+		sx, sy := c.rng.Intn(w), 4+c.rng.Intn(h-5)
 		distToQueen := math.Sqrt(float64((sx-cx)*(sx-cx) + (sy-cy)*(sy-cy)))
 		if distToQueen < 20.0 {
 			i-- 
@@ -101,8 +112,8 @@ func NewColony(w, h int) *Colony {
 
 func (c *Colony) Tick() {
 	for _, s := range c.Spiders {
-		if rand.Float64() < 0.08 {
-			dx, dy := rand.Intn(3)-1, rand.Intn(3)-1
+		if c.rng.Float64() < 0.08 {
+			dx, dy := c.rng.Intn(3)-1, c.rng.Intn(3)-1
 			nx, ny := s.OriginX+dx, s.OriginY+dy
 			if nx >= 0 && nx < c.Width && ny >= 0 && ny < c.Height {
 				s.X, s.Y = nx, ny
@@ -130,25 +141,24 @@ func (c *Colony) Tick() {
 }
 
 func (c *Colony) updateAnt(a *Ant) {
-	cx, cy := c.Width/2, c.Height/2
-	
 	// 1. CARRIER LOGIC: LASER FOCUS ON HOME
 	if a.HasFood {
-		// Use BFS to find path through tunnels, or greedy if blocked
-		nextX, nextY := c.findNextStepHome(a.X, a.Y, cx, cy)
-		
-		if c.Grid[nextY][nextX] == Dirt {
-			c.Grid[nextY][nextX] = Tunnel // Force dig if that's the way home
-		}
+		// Follow the cheapest path home through the tunnels, around the spiders
+		nextX, nextY := c.findNextStepHome(a)
 		
+		// Step first, so digging its own way does not drop its path
 		a.X, a.Y = nextX, nextY
+		if c.Grid[a.Y][a.X] == Dirt {
+			c.set(a.X, a.Y, Tunnel)
+		}
 
 		// Release proximity
-		if math.Abs(float64(a.X-cx)) <= 3 && math.Abs(float64(a.Y-cy)) <= 2 {
+		if c.home(a.X, a.Y) {
 			a.HasFood = false
+			a.path = nil
 			a.Activity = "foraging"
-			if rand.Float64() < 0.5 { a.Activity = "digging" }
-			a.TargetX = rand.Intn(c.Width)
+			if c.rng.Float64() < 0.5 { a.Activity = "digging" }
+			a.TargetX = c.rng.Intn(c.Width)
 		}
 		return
 	}
@@ -181,7 +191,6 @@ func (c *Colony) updateAnt(a *Ant) {
 	}
 
 	// Choose move
-	moves := [][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
 	bestDX, bestDY := 0, 0
 	maxScore := -100000.0
 
@@ -210,7 +219,7 @@ func (c *Colony) updateAnt(a *Ant) {
 			score += 100.0 
 		}
 
-		score += rand.Float64() * 20.0
+		score += c.rng.Float64() * 20.0
 
 		if score > maxScore {
 			maxScore = score
@@ -222,49 +231,14 @@ func (c *Colony) updateAnt(a *Ant) {
 		a.X += bestDX
 		a.Y += bestDY
 		if c.Grid[a.Y][a.X] == Dirt {
-			c.Grid[a.Y][a.X] = Tunnel
+			c.set(a.X, a.Y, Tunnel)
 		}
 		if c.Grid[a.Y][a.X] == Food {
 			a.HasFood = true
-			c.Grid[a.Y][a.X] = Empty
+			c.set(a.X, a.Y, Empty)
 			a.Activity = "returning"
 		}
 	} else {
-		a.TargetX = rand.Intn(c.Width)
-	}
-}
-
-// findNextStepHome uses simple greedy logic with tunnel bias for home-bound carriers
-func (c *Colony) findNextStepHome(startX, startY, targetX, targetY int) (int, int) {
-	moves := [][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
-	bestX, bestY := startX, startY
-	maxScore := -100000.0
-
-	for _, m := range moves {
-		nx, ny := startX+m[0], startY+m[1]
-		if nx < 0 || nx >= c.Width || ny < 0 || ny >= c.Height { continue }
-
-		dist := math.Sqrt(float64((nx-targetX)*(nx-targetX) + (ny-targetY)*(ny-targetY)))
-		score := 5000.0 - dist
-
-		// Carriers hate dirt but will dig if it gets them home
-		if c.Grid[ny][nx] == Dirt {
-			score -= 50.0 
-		} else {
-			score += 100.0 
-		}
-
-		// Avoid spiders even when carrying food
-		for _, s := range c.Spiders {
-			if math.Abs(float64(nx-s.X)) <= 1 && math.Abs(float64(ny-s.Y)) <= 1 {
-				score -= 20000.0
-			}
-		}
-
-		if score > maxScore {
-			maxScore = score
-			bestX, bestY = nx, ny
-		}
+		a.TargetX = c.rng.Intn(c.Width)
 	}
-	return bestX, bestY
 }
@@ -0,0 +1,218 @@
+package colony
+
+import (
+	"container/heap"
+	"slices"
+)
+
+// Step costs for the pathfinder. Tunnels are cheap; the open surface costs
+// a little more, being exposed; dirt has to be dug first. A spider's zone,
+// every cell it could strike from anywhere on its beat, is never entered;
+// an ant caught inside one pays spiderCost per zone cell on its way out.
+const (
+	tunnelCost  = 1
+	surfaceCost = 2
+	digCost     = 10
+	spiderCost  = 200
+)
+
+// spiderRange is how far from its origin a spider can strike: it roams one
+// cell around the origin and kills within one cell of where it stands.
+const spiderRange = 2
+
+// moves are the eight steps an ant can take.
+var moves = [][2]int{{0, 1}, {0, -1}, {1, 0}, {-1, 0}, {1, 1}, {1, -1}, {-1, 1}, {-1, -1}}
+
+// pathEntry is a queued cell with the cost of the best way to it found so
+// far. Entries are never updated in place; a cheaper way pushes the cell
+// again and the old entry is skipped when it surfaces.
+type pathEntry struct {
+	cost int
+	idx  int
+}
+
+// pathHeap is a min-heap of cells keyed on cost.
+type pathHeap []pathEntry
+
+func (h pathHeap) Len() int            { return len(h) }
+func (h pathHeap) Less(i, j int) bool  { return h[i].cost < h[j].cost }
+func (h pathHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *pathHeap) Push(x interface{}) { *h = append(*h, x.(pathEntry)) }
+func (h *pathHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// set changes a cell of the grid and drops the cached paths home the
+// change affects: those that cross the cell, and, if it got cheaper to
+// enter, those a way through it might now beat.
+func (c *Colony) set(x, y int, t CellType) {
+	old := c.Grid[y][x]
+	if old == t {
+		return
+	}
+	c.Grid[y][x] = t
+	cell, cheaper := [2]int{x, y}, cellCost(t) < cellCost(old)
+	for _, a := range c.Ants {
+		if len(a.path) == 0 || (a.X == x && a.Y == y) {
+			continue
+		}
+		if a.onPath[cell] || (cheaper && c.leastVia(a, x, y) < a.pathCost) {
+			a.path = nil
+		}
+	}
+}
+
+// leastVia is a lower bound on the cost of a way home from where a stands
+// through (x, y): every other step there and on to the chamber costs at
+// least tunnelCost.
+func (c *Colony) leastVia(a *Ant, x, y int) int {
+	to := max(abs(x-a.X), abs(y-a.Y)) - 1
+	cx, cy := c.Width/2, c.Height/2
+	on := max(abs(x-cx)-3, abs(y-cy)-2, 0)
+	return (to+on)*tunnelCost + cellCost(c.Grid[y][x])
+}
+
+// home reports whether (x, y) is in the queen's chamber, where carriers
+// drop their food.
+func (c *Colony) home(x, y int) bool {
+	cx, cy := c.Width/2, c.Height/2
+	return abs(x-cx) <= 3 && abs(y-cy) <= 2
+}
+
+// lethal reports whether a spider could kill an ant on (x, y) right now.
+func (c *Colony) lethal(x, y int) bool {
+	for _, s := range c.Spiders {
+		if abs(x-s.X) <= 1 && abs(y-s.Y) <= 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// cellCost is the cost of stepping onto a cell of type t, spiders aside.
+func cellCost(t CellType) int {
+	switch t {
+	case Dirt:
+		return digCost
+	case Empty:
+		return surfaceCost
+	}
+	return tunnelCost
+}
+
+// inZone reports whether the spider could strike (x, y) from somewhere on
+// its beat.
+func (s *Spider) inZone(x, y int) bool {
+	return abs(x-s.OriginX) <= spiderRange && abs(y-s.OriginY) <= spiderRange
+}
+
+// stepCost is the cost of stepping onto (x, y), or -1 if it must not be
+// entered. Spider zones are forbidden, except those of the spiders in
+// escape, the ones whose zone the ant set out from.
+func (c *Colony) stepCost(x, y int, escape []*Spider) int {
+	if c.lethal(x, y) {
+		return -1
+	}
+	cost := cellCost(c.Grid[y][x])
+	for _, s := range c.Spiders {
+		if !s.inZone(x, y) {
+			continue
+		}
+		if !slices.Contains(escape, s) {
+			return -1
+		}
+		cost += spiderCost
+	}
+	return cost
+}
+
+// pathHome finds the cheapest way from (x, y) into the queen's chamber
+// with Dijkstra's algorithm. The path leaves out the start; it is nil
+// when the ant is already home or no way avoids the spiders. rest holds,
+// for each cell of the path, the cost of the way on from it, and cost is
+// the cost of the whole path.
+func (c *Colony) pathHome(x, y int) (path [][2]int, rest []int, cost int) {
+	var escape []*Spider
+	for _, s := range c.Spiders {
+		if s.inZone(x, y) {
+			escape = append(escape, s)
+		}
+	}
+	n := c.Width * c.Height
+	dist, prev := make([]int, n), make([]int, n)
+	for i := range dist {
+		dist[i], prev[i] = -1, -1
+	}
+	start := y*c.Width + x
+	dist[start] = 0
+	h := &pathHeap{{cost: 0, idx: start}}
+	for h.Len() > 0 {
+		e := heap.Pop(h).(pathEntry)
+		if e.cost != dist[e.idx] {
+			continue
+		}
+		ex, ey := e.idx%c.Width, e.idx/c.Width
+		if c.home(ex, ey) {
+			for i := e.idx; i != start; i = prev[i] {
+				path = append(path, [2]int{i % c.Width, i / c.Width})
+				rest = append(rest, e.cost-dist[i])
+			}
+			slices.Reverse(path)
+			slices.Reverse(rest)
+			return path, rest, e.cost
+		}
+		for _, m := range moves {
+			nx, ny := ex+m[0], ey+m[1]
+			if nx < 0 || nx >= c.Width || ny < 0 || ny >= c.Height {
+				continue
+			}
+			step := c.stepCost(nx, ny, escape)
+			if step < 0 {
+				continue
+			}
+			ni := ny*c.Width + nx
+			if cost := e.cost + step; dist[ni] < 0 || cost < dist[ni] {
+				dist[ni], prev[ni] = cost, e.idx
+				heap.Push(h, pathEntry{cost: cost, idx: ni})
+			}
+		}
+	}
+	return nil, nil, 0
+}
+
+// findNextStepHome is the next cell on a carrier's way home. The ant keeps
+// its path until set drops it or a spider moves onto it, then plans a new
+// one. With no safe way home it stays put.
+func (c *Colony) findNextStepHome(a *Ant) (int, int) {
+	if len(a.path) > 0 {
+		next := a.path[0]
+		if abs(next[0]-a.X) > 1 || abs(next[1]-a.Y) > 1 || c.lethal(next[0], next[1]) {
+			a.path = nil
+		}
+	}
+	if len(a.path) == 0 {
+		a.path, a.rest, a.pathCost = c.pathHome(a.X, a.Y)
+		a.onPath = make(map[[2]int]bool, len(a.path))
+		for _, p := range a.path {
+			a.onPath[p] = true
+		}
+	}
+	if len(a.path) == 0 {
+		return a.X, a.Y
+	}
+	next := a.path[0]
+	a.path, a.pathCost = a.path[1:], a.rest[0]
+	a.rest = a.rest[1:]
+	delete(a.onPath, next)
+	return next[0], next[1]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
@@ -0,0 +1,121 @@
+package colony
+
+import "testing"
+
+// tunnels is a width x height colony dug out everywhere, with no ants and
+// no spiders. The queen's chamber is the usual 7x5 in the middle.
+func tunnels(width, height int) *Colony {
+	c := &Colony{Width: width, Height: height, Grid: make([][]CellType, height)}
+	for y := range c.Grid {
+		c.Grid[y] = make([]CellType, width)
+		for x := range c.Grid[y] {
+			c.Grid[y][x] = Tunnel
+		}
+	}
+	return c
+}
+
+func TestCellCost(t *testing.T) {
+	tests := []struct {
+		t    CellType
+		want int
+	}{
+		{Empty, surfaceCost},
+		{Dirt, digCost},
+		{Tunnel, tunnelCost},
+		{Food, tunnelCost},
+		{Queen, tunnelCost},
+	}
+	for _, tt := range tests {
+		if got := cellCost(tt.t); got != tt.want {
+			t.Errorf("cellCost(%d) = %d, want %d", tt.t, got, tt.want)
+		}
+	}
+}
+
+func TestPathHome(t *testing.T) {
+	tests := []struct {
+		name    string
+		width   int
+		height  int
+		x, y    int
+		spiders []*Spider
+		ok      bool
+		cost    int
+	}{
+		{"along the tunnel", 21, 9, 0, 4, nil, true, 7},
+		{"already home", 21, 9, 10, 4, nil, false, 0},
+		{"round a spider", 21, 15, 0, 7, []*Spider{{X: 3, Y: 7, OriginX: 3, OriginY: 7}}, true, 9},
+		// Two zone cells to cross on the way out, then three more steps
+		{"out of a zone", 21, 9, 2, 4, []*Spider{{X: 2, Y: 8, OriginX: 2, OriginY: 4}}, true, 2*(tunnelCost+spiderCost) + 3},
+		{"cut off", 21, 5, 0, 2, []*Spider{{X: 3, Y: 2, OriginX: 3, OriginY: 2}}, false, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tunnels(tt.width, tt.height)
+			c.Spiders = tt.spiders
+			path, rest, cost := c.pathHome(tt.x, tt.y)
+			if (path != nil) != tt.ok {
+				t.Fatalf("path = %v, want one: %v", path, tt.ok)
+			}
+			if !tt.ok {
+				return
+			}
+			if cost != tt.cost {
+				t.Errorf("cost = %d, want %d", cost, tt.cost)
+			}
+			if len(rest) != len(path) || rest[len(rest)-1] != 0 {
+				t.Errorf("rest = %v for path %v", rest, path)
+			}
+			if end := path[len(path)-1]; !c.home(end[0], end[1]) {
+				t.Errorf("path %v ends outside the chamber", path)
+			}
+			px, py := tt.x, tt.y
+			for _, p := range path {
+				if abs(p[0]-px) > 1 || abs(p[1]-py) > 1 {
+					t.Fatalf("path %v jumps from %d,%d to %v", path, px, py, p)
+				}
+				for _, s := range tt.spiders {
+					if s.inZone(p[0], p[1]) && !s.inZone(tt.x, tt.y) {
+						t.Errorf("path %v enters the zone of the spider at %d,%d", path, s.OriginX, s.OriginY)
+					}
+				}
+				px, py = p[0], p[1]
+			}
+		})
+	}
+}
+
+func TestSetDropsPath(t *testing.T) {
+	tests := []struct {
+		name string
+		// at picks the cell to change from the ant's path
+		at   func(path [][2]int) [2]int
+		t    CellType
+		keep bool
+	}{
+		{"on the path", func(path [][2]int) [2]int { return path[0] }, Dirt, false},
+		{"dearer off the path", func([][2]int) [2]int { return [2]int{20, 8} }, Dirt, true},
+		{"cheaper far off", func([][2]int) [2]int { return [2]int{20, 0} }, Tunnel, true},
+		{"cheaper shortcut", func([][2]int) [2]int { return [2]int{3, 0} }, Tunnel, false},
+		{"unchanged", func(path [][2]int) [2]int { return path[0] }, Tunnel, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A wall of dirt across column 3 makes the way home cost a dig
+			c := tunnels(21, 9)
+			for y := 0; y < c.Height; y++ {
+				c.Grid[y][3] = Dirt
+			}
+			c.Grid[0][20] = Dirt
+			a := &Ant{X: 0, Y: 4, HasFood: true}
+			c.Ants = []*Ant{a}
+			a.X, a.Y = c.findNextStepHome(a)
+			cell := tt.at(a.path)
+			c.set(cell[0], cell[1], tt.t)
+			if kept := len(a.path) > 0; kept != tt.keep {
+				t.Errorf("set %v to %d: path kept %v, want %v", cell, tt.t, kept, tt.keep)
+			}
+		})
+	}
+}
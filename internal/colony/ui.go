@@ -7,6 +7,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"atlas.games/internal/registry"
 )
 
 var (
@@ -28,10 +29,21 @@ type Model struct {
 	showingHelp bool
 }
 
-func NewModel() Model {
+func init() {
+	registry.Register(registry.Game{
+		ID:          "colony",
+		Title:       "Tactical Colony",
+		Description: "Manage an ant colony, forage for food and avoid territorial spiders.",
+		Category:    registry.Simulation,
+		Order:       1,
+		New:         func(seed int64) tea.Model { return NewModel(seed) },
+	})
+}
+
+func NewModel(seed int64) Model {
 	w, h := 120, 40
 	return Model{
-		colony: NewColony(w, h),
+		colony: NewColony(w, h, seed),
 		width:  w,
 		height: h,
 	}
@@ -48,7 +60,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		case "q", "ctrl+c", "esc":
 			return m, tea.Quit
 		case "r":
-			m.colony = NewColony(m.width, m.height)
+			m.colony = NewColony(m.width, m.height, time.Now().UnixNano())
 			m.showingHelp = false
 			return m, tick()
 		case "h":
@@ -79,6 +91,9 @@ func (m Model) View() string {
 		sb.WriteString("  " + lipgloss.NewStyle().Bold(true).Render("THE SURVIVAL:") + "\n")
 		sb.WriteString("  - Spiders (*) stay within 8 squares of their origin point.\n")
 		sb.WriteString("  - Ants will try to avoid spiders, but may stumble into them while digging.\n")
+		sb.WriteString("  - Carriers plan the cheapest way home: along tunnels, digging only when it saves a long detour,\n")
+		sb.WriteString("    and never into a spider's zone. They plan again when their path\n")
+		sb.WriteString("    changes or a new tunnel could make a cheaper way.\n")
 		sb.WriteString("  - If an ant is eaten, the population decreases. Reset (R) to restart colony.\n\n")
 
 		sb.WriteString("  [H] Close Documentation  [R] Reset Colony  [Q] Exit\n")
@@ -125,7 +140,7 @@ func (m Model) View() string {
 		sb.WriteString("  " + strings.Join(buffer[y], "") + "\n")
 	}
 
-	sb.WriteString(fmt.Sprintf("\n  Workers: %d | [H] Help | [R] Reset | [Q] Exit", len(m.colony.Ants)))
+	sb.WriteString(fmt.Sprintf("\n  Workers: %d | Seed: %d | [H] Help | [R] Reset | [Q] Exit", len(m.colony.Ants), m.colony.Seed))
 	return sb.String()
 }
 
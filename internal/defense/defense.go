@@ -3,7 +3,6 @@ package defense
 import (
 	"math"
 	"math/rand"
-	"time"
 )
 
 type CellType int
@@ -55,14 +54,17 @@ type Game struct {
 	Wave          int
 	TickCount     int
 	NextWaveIn    int // Ticks until next wave
+	Seed          int64
+	rng           *rand.Rand
 }
 
-func NewGame(w, h int) *Game {
-	rand.Seed(time.Now().UnixNano())
+func NewGame(w, h int, seed int64) *Game {
 	g := &Game{
 		Width:      w,
 		Height:     h,
 		Grid:       make([][]CellType, h),
+		Seed:       seed,
+		rng:        rand.New(rand.NewSource(seed)),
 		Gold:       200, // Doubled starting gold
 		Health:     50,  // Increased base health
 		Wave:       0,
@@ -87,17 +89,17 @@ func (g *Game) generatePath() {
 
 	for x < g.Width-2 {
 		// Move right 5-8 steps
-		steps := 6 + rand.Intn(4) // Slightly longer segments
+		steps := 6 + g.rng.Intn(4) // Slightly longer segments
 		for i := 0; i < steps && x < g.Width-2; i++ {
 			x++
 			g.Path = append(g.Path, [2]int{x, y})
 			g.Grid[y][x] = Path
 		}
 		// Move up or down
-		dy := 2 + rand.Intn(2)
+		dy := 2 + g.rng.Intn(2)
 		if y > g.Height-7 { dy = -dy } 
 		if y < 6 { dy = int(math.Abs(float64(dy))) } 
-		if rand.Float64() < 0.5 { dy = -dy }
+		if g.rng.Float64() < 0.5 { dy = -dy }
 
 		direction := 1
 		if dy < 0 { direction = -1; dy = -dy }
@@ -8,6 +8,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"atlas.games/internal/registry"
 )
 
 var (
@@ -38,10 +39,21 @@ type Model struct {
 	gameOver    bool
 }
 
-func NewModel() Model {
+func init() {
+	registry.Register(registry.Game{
+		ID:          "defense",
+		Title:       "Atlas Defense",
+		Description: "Tower defense. Build turrets to stop data corruption reaching the core.",
+		Category:    registry.Strategy,
+		Order:       2,
+		New:         func(seed int64) tea.Model { return NewModel(seed) },
+	})
+}
+
+func NewModel(seed int64) Model {
 	w, h := 60, 25
 	return Model{
-		game:    NewGame(w, h),
+		game:    NewGame(w, h, seed),
 		width:   w,
 		height:  h,
 		cursorX: w / 2,
@@ -80,7 +92,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.game.SellTower(m.cursorX, m.cursorY)
 			}
 		case "r":
-			m.game = NewGame(m.width, m.height)
+			m.game = NewGame(m.width, m.height, time.Now().UnixNano())
 			m.gameOver = false
 			return m, tick()
 		case "h":
@@ -190,8 +202,8 @@ func (m Model) View() string {
 		sb.WriteString("  " + strings.Join(buffer[y], "") + "\n")
 	}
 
-	status := fmt.Sprintf("\n  " + goldStyle.Render("GOLD: %d") + " | " + healthStyle.Render("HEALTH: %d") + " | WAVE: %d | NEXT: %d", 
-		m.game.Gold, m.game.Health, m.game.Wave, m.game.NextWaveIn)
+	status := fmt.Sprintf("\n  " + goldStyle.Render("GOLD: %d") + " | " + healthStyle.Render("HEALTH: %d") + " | WAVE: %d | NEXT: %d | SEED: %d", 
+		m.game.Gold, m.game.Health, m.game.Wave, m.game.NextWaveIn, m.game.Seed)
 	
 	if m.gameOver {
 		status += " | " + lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true).Render("GAME OVER (R to Restart)")
@@ -0,0 +1,271 @@
+// Package gen implements the headless "gen" subcommand, which runs the map
+// generators to completion without a terminal UI.
+package gen
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"atlas.games/internal/city"
+	"atlas.games/internal/mapio"
+	"atlas.games/internal/solver"
+	"atlas.games/internal/warlord"
+	"atlas.games/internal/wfc"
+)
+
+// generator builds a finished map from the parsed flags.
+type generator func(opts options) (*mapio.Map, error)
+
+var generators = map[string]generator{
+	"land":    generateLand,
+	"city":    generateCity,
+	"warlord": generateWarlord,
+}
+
+const usage = `usage: atlas.games gen <land|city|warlord> [flags]
+
+Runs a generator to completion and writes the map to stdout or a file.
+
+flags:
+`
+
+type options struct {
+	width, height int
+	seed          int64
+	format        string
+	out           string
+	tileset       string
+	sample        string
+	pins          string
+	passes        string
+	seeding       string
+	topology      string
+	wrap          bool
+	patternSize   int
+	scale         int
+	exits         string
+	graph         string
+	report        string
+
+	stderr io.Writer // Where warnings and usage go, see Run
+}
+
+func newFlagSet(name string, opts *options) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(opts.stderr)
+	fs.IntVar(&opts.width, "width", 200, "map width in tiles")
+	fs.IntVar(&opts.height, "height", 60, "map height in tiles")
+	fs.Int64Var(&opts.seed, "seed", 0, "generation seed (default: a fresh seed)")
+	fs.StringVar(&opts.format, "format", "plain", "output format: "+strings.Join(mapio.Formats(), ", "))
+	fs.IntVar(&opts.scale, "scale", mapio.DefaultScale, "pixels per tile for png, svg, tmx and tmj")
+	fs.StringVar(&opts.out, "o", "", "output file (default stdout)")
+	fs.StringVar(&opts.topology, "topology", "square", "land and city: grid topology ("+strings.Join(solver.Topologies(), ", ")+"); city has no moore")
+	fs.BoolVar(&opts.wrap, "wrap", false, "land and city: join opposite edges so the map tiles")
+	fs.StringVar(&opts.tileset, "tileset", wfc.DefaultPreset, "land only: tileset .piml file or preset ("+strings.Join(wfc.Presets(), ", ")+")")
+	fs.StringVar(&opts.sample, "sample", "", "land only: learn from an ASCII sample file or preset ("+strings.Join(wfc.SamplePresets(), ", ")+") instead of the tileset rules")
+	fs.StringVar(&opts.pins, "pins", "", "land only: pin tiles from a constraint file painted in the Land Creator")
+	fs.StringVar(&opts.seeding, "seeding", "uniform", "land only: where biome seeds go ("+strings.Join(wfc.Seedings, ", ")+"); noise follows height and moisture")
+	fs.StringVar(&opts.passes, "passes", "", "land only: terrain passes to run after generation, comma separated ("+strings.Join(wfc.Passes(), ", ")+") or all")
+	fs.StringVar(&opts.exits, "exits", "", "city only: edges the road network must leave by, comma separated (top, right, bottom, left) or all")
+	fs.StringVar(&opts.graph, "graph", "", "city only: also write the road graph to this file, as GraphML if it ends in .graphml and JSON otherwise")
+	fs.StringVar(&opts.report, "report", "", "city only: also write the city metrics report to this file as JSON")
+	fs.IntVar(&opts.patternSize, "n", wfc.DefaultPatternSize, "land only: pattern size for -sample")
+	fs.Usage = func() {
+		fmt.Fprint(fs.Output(), usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// Run executes "gen" with the arguments that follow it on the command line.
+// Maps go to stdout unless -o names a file; usage and warnings go to stderr.
+func Run(args []string, stdout, stderr io.Writer) error {
+	opts := options{stderr: stderr}
+	if len(args) == 0 || strings.HasPrefix(args[0], "-") {
+		newFlagSet("gen", &opts).Usage()
+		return errors.New("missing generator name")
+	}
+
+	name := args[0]
+	gen, ok := generators[name]
+	if !ok {
+		return fmt.Errorf("unknown generator %q (want land, city or warlord)", name)
+	}
+
+	fs := newFlagSet("gen "+name, &opts)
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	seedSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSet = true
+		}
+	})
+	if !seedSet {
+		opts.seed = time.Now().UnixNano()
+	}
+
+	if opts.width <= 0 || opts.height <= 0 {
+		return fmt.Errorf("invalid size %dx%d", opts.width, opts.height)
+	}
+	write, err := mapio.Format(opts.format, mapio.Options{Scale: opts.scale})
+	if err != nil {
+		return err
+	}
+
+	m, err := gen(opts)
+	if err != nil {
+		return err
+	}
+
+	if opts.out == "" {
+		return write(stdout, m)
+	}
+	return mapio.Save(opts.out, m, write)
+}
+
+func generateLand(opts options) (*mapio.Map, error) {
+	ts, err := wfc.LoadTileset(opts.tileset)
+	if err != nil {
+		return nil, err
+	}
+	passes, err := wfc.ParsePasses(opts.passes)
+	if err != nil {
+		return nil, err
+	}
+	if opts.sample != "" {
+		return generateSample(opts, ts, passes)
+	}
+	topo, err := topology(opts)
+	if err != nil {
+		return nil, err
+	}
+	if _, hex := topo.(solver.Hex); hex && len(passes) > 0 {
+		return nil, errors.New("terrain passes need a square or moore grid")
+	}
+	seeding, err := wfc.ParseSeeding(opts.seeding)
+	if err != nil {
+		return nil, err
+	}
+	var pins *wfc.Constraints
+	if opts.pins != "" {
+		if pins, err = wfc.LoadConstraints(opts.pins, ts); err != nil {
+			return nil, err
+		}
+	}
+	w := wfc.NewSeededWFC(opts.width, opts.height, opts.seed, topo, ts, seeding, pins)
+	if err := solve(w.Solver, w.Step); err != nil {
+		return nil, err
+	}
+	if w.Rejected > 0 {
+		fmt.Fprintf(opts.stderr, "gen: %d pins clashed with their neighbors and were skipped\n", w.Rejected)
+	}
+	m := w.Map()
+	if len(passes) > 0 {
+		t := w.Terrain()
+		t.Run(passes)
+		t.Apply(m)
+	}
+	return m, nil
+}
+
+// maxRestarts is how many times a solver may start the map over before
+// gen gives up. Backtracking gets out of most contradictions, but rules
+// that can never be satisfied would restart forever.
+const maxRestarts = 100
+
+// solve runs step until the map is finished, failing once s has started
+// over more than maxRestarts times.
+func solve[T ~int](s *solver.Solver[T], step func() bool) error {
+	for !step() {
+		if s.Restarts > maxRestarts {
+			return fmt.Errorf("no solution after %d restarts; the tileset, sample or pins may contradict each other", maxRestarts)
+		}
+	}
+	return nil
+}
+
+// topology builds the -topology and -wrap grid.
+func topology(opts options) (solver.Topology, error) {
+	topo, err := solver.NewTopology(opts.topology, opts.wrap)
+	if err != nil {
+		return nil, err
+	}
+	if _, hex := topo.(solver.Hex); hex && opts.wrap && opts.height%2 != 0 {
+		return nil, fmt.Errorf("wrapped hex maps need an even height, got %d", opts.height)
+	}
+	return topo, nil
+}
+
+// generateSample runs the overlapping model on a sample drawn with the
+// sketch characters of ts, then the selected terrain passes.
+func generateSample(opts options, ts *wfc.Tileset, passes map[string]bool) (*mapio.Map, error) {
+	sample, err := wfc.LoadSample(opts.sample, ts)
+	if err != nil {
+		return nil, err
+	}
+	o, err := wfc.NewOverlap(opts.width, opts.height, opts.seed, ts, sample, opts.patternSize)
+	if err != nil {
+		return nil, err
+	}
+	if err := solve(o.Solver, o.Step); err != nil {
+		return nil, err
+	}
+	m := o.Map()
+	if len(passes) > 0 {
+		t := o.Terrain()
+		t.Run(passes)
+		t.Apply(m)
+	}
+	return m, nil
+}
+
+func generateCity(opts options) (*mapio.Map, error) {
+	if opts.topology == "moore" {
+		return nil, errors.New("city tiles have no corner sockets, use -topology square or hex")
+	}
+	topo, err := topology(opts)
+	if err != nil {
+		return nil, err
+	}
+	exits, err := city.ParseExits(opts.exits)
+	if err != nil {
+		return nil, err
+	}
+	w := city.NewConnectedWFC(opts.width, opts.height, opts.seed, topo, exits)
+	if err := solve(w.Solver, w.Step); err != nil {
+		return nil, err
+	}
+	if w.Network.Exits < len(exits) && !opts.wrap {
+		fmt.Fprintf(opts.stderr, "gen: only %d of %d exits joined the road network after %d regenerations\n", w.Network.Exits, len(exits), w.Regenerated)
+	}
+	if opts.graph != "" {
+		if err := city.SaveGraph(opts.graph, w.Graph()); err != nil {
+			return nil, err
+		}
+	}
+	if opts.report != "" {
+		if err := city.SaveReport(opts.report, w.Report()); err != nil {
+			return nil, err
+		}
+	}
+	return w.Map(), nil
+}
+
+// generateWarlord builds a Warlord campaign map. Its city and princess
+// placement needs some room around the central stronghold.
+func generateWarlord(opts options) (*mapio.Map, error) {
+	if opts.width < 40 || opts.height < 30 {
+		return nil, fmt.Errorf("warlord maps need at least 40x30, got %dx%d", opts.width, opts.height)
+	}
+	return warlord.NewGame(opts.width, opts.height, opts.seed).Map(), nil
+}
@@ -0,0 +1,120 @@
+package mapio
+
+import (
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"io"
+)
+
+// DefaultScale is the size of one tile in pixels for the image formats.
+const DefaultScale = 8
+
+// roadGround is painted under linked cells in SVG so strokes stand out.
+const roadGround = "#1c1c1c"
+
+// PNG returns a writer that draws each tile as a scale x scale block of
+// its color. Hex maps shift odd rows right by half a block.
+func PNG(scale int) Writer {
+	if scale < 1 {
+		scale = 1
+	}
+	return func(w io.Writer, m *Map) error {
+		width := m.Width * scale
+		if m.Hex {
+			width += scale / 2
+		}
+		img := image.NewRGBA(image.Rect(0, 0, width, m.Height*scale))
+		for y, row := range m.Cells {
+			shift := 0
+			if m.Hex && y%2 == 1 {
+				shift = scale / 2
+			}
+			for x, c := range row {
+				block := image.Rect(x*scale+shift, y*scale, (x+1)*scale+shift, (y+1)*scale)
+				draw.Draw(img, block, image.NewUniform(RGB(c.Color)), image.Point{}, draw.Src)
+			}
+		}
+		return png.Encode(w, img)
+	}
+}
+
+// linkEnds are the edge midpoints of a unit cell, indexed like the Link
+// bits. hexLinkEnds are the points halfway to each hex neighbor.
+var (
+	linkEnds    = [][2]float64{{0.5, 0}, {1, 0.5}, {0.5, 1}, {0, 0.5}}
+	hexLinkEnds = [][2]float64{{0.75, 0}, {1, 0.5}, {0.75, 1}, {0.25, 1}, {0, 0.5}, {0.25, 0}}
+)
+
+// SVG returns a writer that draws tiles as colored squares, except that
+// cells with Links are drawn as strokes from their center to each linked
+// edge. The drawing uses tile units, scaled to scale pixels per tile. Hex
+// maps shift odd rows right by half a tile and link toward the six
+// neighbors. Labels are drawn as text centered on their cell.
+func SVG(scale int) Writer {
+	if scale < 1 {
+		scale = 1
+	}
+	return func(w io.Writer, m *Map) error {
+		width, ends := float64(m.Width), linkEnds
+		if m.Hex {
+			width, ends = width+0.5, hexLinkEnds
+		}
+		shift := func(y int) float64 {
+			if m.Hex && y%2 == 1 {
+				return 0.5
+			}
+			return 0
+		}
+
+		bw := bufio.NewWriter(w)
+		fmt.Fprintf(bw, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%d" viewBox="0 0 %g %d">`+"\n",
+			width*float64(scale), m.Height*scale, width, m.Height)
+		fmt.Fprintf(bw, "<title>%s map, seed %d</title>\n", m.Kind, m.Seed)
+
+		bw.WriteString(`<g shape-rendering="crispEdges">` + "\n")
+		for y, row := range m.Cells {
+			for x, c := range row {
+				fill := hex(RGB(c.Color))
+				if c.Links != 0 {
+					fill = roadGround
+				}
+				fmt.Fprintf(bw, `<rect x="%g" y="%d" width="1" height="1" fill="%s"/>`+"\n", float64(x)+shift(y), y, fill)
+			}
+		}
+		bw.WriteString("</g>\n")
+
+		bw.WriteString(`<g fill="none" stroke-width="0.4" stroke-linecap="round">` + "\n")
+		for y, row := range m.Cells {
+			for x, c := range row {
+				if c.Links == 0 {
+					continue
+				}
+				fmt.Fprintf(bw, `<path stroke="%s" d="`, hex(RGB(c.Color)))
+				left := float64(x) + shift(y)
+				for d, end := range ends {
+					if c.Links&(1<<d) != 0 {
+						fmt.Fprintf(bw, "M%g %d.5L%g %g", left+0.5, y, left+end[0], float64(y)+end[1])
+					}
+				}
+				bw.WriteString(`"/>` + "\n")
+			}
+		}
+		bw.WriteString("</g>\n")
+
+		if len(m.Labels) > 0 {
+			bw.WriteString(`<g font-family="sans-serif" font-size="1.6" text-anchor="middle" fill="#ffffff" stroke="#000000" stroke-width="0.15" paint-order="stroke">` + "\n")
+			for _, l := range m.Labels {
+				fmt.Fprintf(bw, `<text x="%g" y="%d.5" dominant-baseline="middle">`, float64(l.X)+shift(l.Y)+0.5, l.Y)
+				xml.EscapeText(bw, []byte(l.Text))
+				bw.WriteString("</text>\n")
+			}
+			bw.WriteString("</g>\n")
+		}
+		bw.WriteString("</svg>\n")
+		return bw.Flush()
+	}
+}
@@ -0,0 +1,252 @@
+// Package mapio holds a renderer-neutral snapshot of a generated grid and
+// the writers that turn it into text or data for use outside the TUI.
+package mapio
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode/utf8"
+)
+
+// Cell is one resolved grid position.
+type Cell struct {
+	Name  string // Stable identifier, e.g. "water" or "road_cross"
+	Glyph string // Terminal glyph used by the TUI
+	Color string // xterm-256 color index, as used with lipgloss.Color
+	Links uint8  // Edges a path leaves through, e.g. road exits; see LinkUp
+}
+
+// Link bits of Cell.Links, in the solver's direction order. On hex maps
+// bit d is solver hex direction d instead, clockwise from north-east.
+const (
+	LinkUp uint8 = 1 << iota
+	LinkRight
+	LinkDown
+	LinkLeft
+)
+
+// Unresolved is the cell reported for positions that have not collapsed yet.
+var Unresolved = Cell{Name: "unresolved", Glyph: "?", Color: "235"}
+
+// Map is a generated grid tagged with the generator and seed that made it.
+type Map struct {
+	Kind   string // Generator name, e.g. "land" or "city"
+	Width  int
+	Height int
+	Seed   int64
+	Cells  [][]Cell // Cells[y][x]
+
+	// Hex maps are pointy-topped hexagons in offset rows, odd rows half a
+	// tile to the right. Wrap maps join opposite edges, so they tile.
+	Hex  bool
+	Wrap bool
+
+	// Legend is every tile the generator can place, in tile type order and
+	// without Unresolved. Exporters that number tiles use it so the numbers
+	// do not depend on which tiles happen to appear.
+	Legend []Cell
+
+	// Labels name places on the map, such as continents and lakes.
+	Labels []Label
+}
+
+// Label is a place name anchored at a cell.
+type Label struct {
+	Text string
+	Kind string // What is named, e.g. "lake" or "continent"
+	X, Y int
+}
+
+// HexGlyph widens a glyph to the two columns a hex cell takes in text.
+func HexGlyph(glyph string) string {
+	if utf8.RuneCountInString(glyph) == 1 {
+		return glyph + glyph
+	}
+	return glyph
+}
+
+// glyph is how c is drawn in text on m.
+func (m *Map) glyph(c Cell) string {
+	if m.Hex {
+		return HexGlyph(c.Glyph)
+	}
+	return c.Glyph
+}
+
+// indent starts row y of a text drawing, shifting odd hex rows.
+func (m *Map) indent(bw *bufio.Writer, y int) {
+	if m.Hex && y%2 == 1 {
+		bw.WriteByte(' ')
+	}
+}
+
+// WritePlain writes the glyphs only, one row per line. Hex cells are two
+// columns wide, with odd rows indented by one.
+func WritePlain(w io.Writer, m *Map) error {
+	bw := bufio.NewWriter(w)
+	for y, row := range m.Cells {
+		m.indent(bw, y)
+		for _, c := range row {
+			bw.WriteString(m.glyph(c))
+		}
+		bw.WriteByte('\n')
+	}
+	return bw.Flush()
+}
+
+// WriteANSI writes the glyphs with 256-color escape codes, regardless of
+// whether w is a terminal.
+func WriteANSI(w io.Writer, m *Map) error {
+	bw := bufio.NewWriter(w)
+	for y, row := range m.Cells {
+		m.indent(bw, y)
+		last := ""
+		for _, c := range row {
+			if c.Color != last {
+				fmt.Fprintf(bw, "\x1b[38;5;%sm", c.Color)
+				last = c.Color
+			}
+			bw.WriteString(m.glyph(c))
+		}
+		bw.WriteString("\x1b[0m\n")
+	}
+	return bw.Flush()
+}
+
+type jsonLegend struct {
+	Glyph string `json:"glyph"`
+	Color string `json:"color"`
+}
+
+type jsonLabel struct {
+	Text string `json:"text"`
+	Kind string `json:"kind"`
+	X    int    `json:"x"`
+	Y    int    `json:"y"`
+}
+
+type jsonMap struct {
+	Kind   string                `json:"kind"`
+	Width  int                   `json:"width"`
+	Height int                   `json:"height"`
+	Seed   int64                 `json:"seed"`
+	Hex    bool                  `json:"hex,omitempty"`
+	Wrap   bool                  `json:"wrap,omitempty"`
+	Legend map[string]jsonLegend `json:"legend"`
+	Rows   []string              `json:"rows"`
+	Tiles  [][]string            `json:"tiles"`
+	Labels []jsonLabel           `json:"labels,omitempty"`
+}
+
+// WriteJSON writes the grid as tile names plus a legend and glyph rows,
+// and any labels.
+func WriteJSON(w io.Writer, m *Map) error {
+	out := jsonMap{
+		Kind:   m.Kind,
+		Width:  m.Width,
+		Height: m.Height,
+		Seed:   m.Seed,
+		Hex:    m.Hex,
+		Wrap:   m.Wrap,
+		Legend: map[string]jsonLegend{},
+		Rows:   make([]string, 0, len(m.Cells)),
+		Tiles:  make([][]string, 0, len(m.Cells)),
+	}
+	for _, row := range m.Cells {
+		var line strings.Builder
+		names := make([]string, 0, len(row))
+		for _, c := range row {
+			line.WriteString(c.Glyph)
+			names = append(names, c.Name)
+			out.Legend[c.Name] = jsonLegend{Glyph: c.Glyph, Color: c.Color}
+		}
+		out.Rows = append(out.Rows, line.String())
+		out.Tiles = append(out.Tiles, names)
+	}
+	for _, l := range m.Labels {
+		out.Labels = append(out.Labels, jsonLabel{Text: l.Text, Kind: l.Kind, X: l.X, Y: l.Y})
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// Writer writes a map in one output format.
+type Writer func(w io.Writer, m *Map) error
+
+// Options tune the writers that need more than the map.
+type Options struct {
+	Scale int // Pixels per tile for png, svg and the Tiled formats
+}
+
+func fixed(w Writer) func(Options) Writer {
+	return func(Options) Writer { return w }
+}
+
+var formats = map[string]func(Options) Writer{
+	"plain": fixed(WritePlain),
+	"ansi":  fixed(WriteANSI),
+	"json":  fixed(WriteJSON),
+	"png":   func(o Options) Writer { return PNG(o.Scale) },
+	"svg":   func(o Options) Writer { return SVG(o.Scale) },
+	"tmx":   func(o Options) Writer { return TMX(o.Scale) },
+	"tmj":   func(o Options) Writer { return TMJ(o.Scale) },
+}
+
+// Format looks up a writer by its CLI name.
+func Format(name string, opts Options) (Writer, error) {
+	f, ok := formats[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q (want one of %s)", name, strings.Join(Formats(), ", "))
+	}
+	return f(opts), nil
+}
+
+// Formats lists the supported format names.
+func Formats() []string {
+	names := make([]string, 0, len(formats))
+	for n := range formats {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save writes m to the named file.
+func Save(name string, m *Map, write Writer) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f, m); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// FileName is the default export name for m, e.g. "land-42.png".
+func FileName(m *Map, ext string) string {
+	return fmt.Sprintf("%s-%d.%s", m.Kind, m.Seed, ext)
+}
+
+// SaveAs writes m to the current directory under FileName, once per
+// format, and returns the files written.
+func SaveAs(m *Map, opts Options, formats ...string) ([]string, error) {
+	var names []string
+	for _, f := range formats {
+		write, err := Format(f, opts)
+		if err != nil {
+			return names, err
+		}
+		name := FileName(m, f)
+		if err := Save(name, m, write); err != nil {
+			return names, err
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
@@ -0,0 +1,49 @@
+package mapio
+
+import (
+	"fmt"
+	"image/color"
+	"strconv"
+	"strings"
+)
+
+// ansi16 are the usual xterm values for the 16 system colors.
+var ansi16 = [16][3]uint8{
+	{0, 0, 0}, {128, 0, 0}, {0, 128, 0}, {128, 128, 0},
+	{0, 0, 128}, {128, 0, 128}, {0, 128, 128}, {192, 192, 192},
+	{128, 128, 128}, {255, 0, 0}, {0, 255, 0}, {255, 255, 0},
+	{0, 0, 255}, {255, 0, 255}, {0, 255, 255}, {255, 255, 255},
+}
+
+// cubeLevels are the channel values of the 6x6x6 color cube.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// RGB converts a Cell color to RGB. It accepts an xterm-256 index or
+// "#rrggbb"; anything else comes out black.
+func RGB(c string) color.RGBA {
+	if strings.HasPrefix(c, "#") && len(c) == 7 {
+		v, err := strconv.ParseUint(c[1:], 16, 32)
+		if err == nil {
+			return color.RGBA{uint8(v >> 16), uint8(v >> 8), uint8(v), 255}
+		}
+	}
+	n, err := strconv.Atoi(c)
+	switch {
+	case err != nil || n < 0 || n > 255:
+		return color.RGBA{A: 255}
+	case n < 16:
+		rgb := ansi16[n]
+		return color.RGBA{rgb[0], rgb[1], rgb[2], 255}
+	case n < 232:
+		n -= 16
+		return color.RGBA{cubeLevels[n/36], cubeLevels[n/6%6], cubeLevels[n%6], 255}
+	default:
+		g := uint8(8 + 10*(n-232))
+		return color.RGBA{g, g, g, 255}
+	}
+}
+
+// hex formats c for SVG.
+func hex(c color.RGBA) string {
+	return fmt.Sprintf("#%02x%02x%02x", c.R, c.G, c.B)
+}
@@ -0,0 +1,311 @@
+package mapio
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// Tiled map export (https://www.mapeditor.org). Both writers produce one
+// orthogonal map with a single tile layer and an embedded tileset generated
+// from the legend: legend entry i is local tile id i and GID i+1, and GID 0
+// (no tile) marks unresolved cells. The tileset has no image; every tile
+// carries its name as its class plus glyph and color properties, which is
+// what game code reading the map needs.
+
+const tiledVersion = "1.10"
+
+// tiledGIDs numbers the grid by legend position.
+func tiledGIDs(m *Map) []int {
+	gid := map[string]int{}
+	for i, c := range m.Legend {
+		gid[c.Name] = i + 1
+	}
+	data := make([]int, 0, m.Width*m.Height)
+	for _, row := range m.Cells {
+		for _, c := range row {
+			data = append(data, gid[c.Name])
+		}
+	}
+	return data
+}
+
+// tiledColor is Tiled's #AARRGGBB color property format.
+func tiledColor(c string) string {
+	rgb := RGB(c)
+	return fmt.Sprintf("#ff%02x%02x%02x", rgb.R, rgb.G, rgb.B)
+}
+
+// tileSize is the tile size in pixels written to the map header.
+func tileSize(scale int) int {
+	if scale < 1 {
+		return DefaultScale
+	}
+	return scale
+}
+
+type tmxProperty struct {
+	Name  string `xml:"name,attr"`
+	Type  string `xml:"type,attr,omitempty"`
+	Value string `xml:"value,attr"`
+}
+
+type tmxProperties struct {
+	Properties []tmxProperty `xml:"property"`
+}
+
+type tmxTile struct {
+	ID         int           `xml:"id,attr"`
+	Class      string        `xml:"type,attr"`
+	Properties tmxProperties `xml:"properties"`
+}
+
+type tmxGrid struct {
+	Orientation string `xml:"orientation,attr" json:"orientation"`
+	Width       int    `xml:"width,attr" json:"width"`
+	Height      int    `xml:"height,attr" json:"height"`
+}
+
+type tmxTileset struct {
+	FirstGID   int       `xml:"firstgid,attr"`
+	Name       string    `xml:"name,attr"`
+	TileWidth  int       `xml:"tilewidth,attr"`
+	TileHeight int       `xml:"tileheight,attr"`
+	TileCount  int       `xml:"tilecount,attr"`
+	Columns    int       `xml:"columns,attr"`
+	Grid       tmxGrid   `xml:"grid"`
+	Tiles      []tmxTile `xml:"tile"`
+}
+
+type tmxData struct {
+	Encoding string `xml:"encoding,attr"`
+	CSV      string `xml:",innerxml"` // Digits and commas only, no escaping needed
+}
+
+type tmxLayer struct {
+	ID     int     `xml:"id,attr"`
+	Name   string  `xml:"name,attr"`
+	Width  int     `xml:"width,attr"`
+	Height int     `xml:"height,attr"`
+	Data   tmxData `xml:"data"`
+}
+
+// tiledShape is the grid layout shared by TMX and TMJ maps.
+type tiledShape struct {
+	Orientation   string `xml:"orientation,attr" json:"orientation"`
+	StaggerAxis   string `xml:"staggeraxis,attr,omitempty" json:"staggeraxis,omitempty"`
+	StaggerIndex  string `xml:"staggerindex,attr,omitempty" json:"staggerindex,omitempty"`
+	HexSideLength int    `xml:"hexsidelength,attr,omitempty" json:"hexsidelength,omitempty"`
+}
+
+// shape lays hex maps out as Tiled's pointy-topped hexagons with odd rows
+// shifted, matching mapio's own drawing.
+func shape(m *Map, size int) tiledShape {
+	if !m.Hex {
+		return tiledShape{Orientation: "orthogonal"}
+	}
+	return tiledShape{Orientation: "hexagonal", StaggerAxis: "y", StaggerIndex: "odd", HexSideLength: size / 2}
+}
+
+type tmxMap struct {
+	XMLName      xml.Name      `xml:"map"`
+	Version      string        `xml:"version,attr"`
+	RenderOrder  string        `xml:"renderorder,attr"`
+	Width        int           `xml:"width,attr"`
+	Height       int           `xml:"height,attr"`
+	TileWidth    int           `xml:"tilewidth,attr"`
+	TileHeight   int           `xml:"tileheight,attr"`
+	Infinite     int           `xml:"infinite,attr"`
+	NextLayerID  int           `xml:"nextlayerid,attr"`
+	NextObjectID int           `xml:"nextobjectid,attr"`
+	Properties   tmxProperties `xml:"properties"`
+	Tileset      tmxTileset    `xml:"tileset"`
+	Layer        tmxLayer      `xml:"layer"`
+
+	tiledShape
+}
+
+// mapProperties are the custom properties of the map. The seed is a
+// string because time-based seeds overflow Tiled's int properties.
+func mapProperties(m *Map) []tmxProperty {
+	return []tmxProperty{
+		{Name: "generator", Value: m.Kind},
+		{Name: "seed", Value: strconv.FormatInt(m.Seed, 10)},
+		{Name: "wrap", Type: "bool", Value: strconv.FormatBool(m.Wrap)},
+	}
+}
+
+func tileProperties(c Cell) []tmxProperty {
+	return []tmxProperty{
+		{Name: "color", Type: "color", Value: tiledColor(c.Color)},
+		{Name: "glyph", Value: c.Glyph},
+	}
+}
+
+// TMX returns a writer for Tiled's XML map format.
+func TMX(scale int) Writer {
+	size := tileSize(scale)
+	return func(w io.Writer, m *Map) error {
+		out := tmxMap{
+			Version:      tiledVersion,
+			tiledShape:   shape(m, size),
+			RenderOrder:  "right-down",
+			Width:        m.Width,
+			Height:       m.Height,
+			TileWidth:    size,
+			TileHeight:   size,
+			NextLayerID:  2,
+			NextObjectID: 1,
+			Properties:   tmxProperties{mapProperties(m)},
+			Tileset: tmxTileset{
+				FirstGID:   1,
+				Name:       m.Kind,
+				TileWidth:  size,
+				TileHeight: size,
+				TileCount:  len(m.Legend),
+				Grid:       tmxGrid{Orientation: "orthogonal", Width: 1, Height: 1},
+			},
+			Layer: tmxLayer{ID: 1, Name: "tiles", Width: m.Width, Height: m.Height},
+		}
+		for i, c := range m.Legend {
+			out.Tileset.Tiles = append(out.Tileset.Tiles, tmxTile{
+				ID:         i,
+				Class:      c.Name,
+				Properties: tmxProperties{tileProperties(c)},
+			})
+		}
+
+		var csv strings.Builder
+		csv.WriteByte('\n')
+		for i, gid := range tiledGIDs(m) {
+			csv.WriteString(strconv.Itoa(gid))
+			if i < m.Width*m.Height-1 {
+				csv.WriteByte(',')
+			}
+			if (i+1)%m.Width == 0 {
+				csv.WriteByte('\n')
+			}
+		}
+		out.Layer.Data = tmxData{Encoding: "csv", CSV: csv.String()}
+
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		enc := xml.NewEncoder(w)
+		enc.Indent("", " ")
+		if err := enc.Encode(out); err != nil {
+			return err
+		}
+		_, err := io.WriteString(w, "\n")
+		return err
+	}
+}
+
+type tmjProperty struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+type tmjTile struct {
+	ID         int           `json:"id"`
+	Type       string        `json:"type"`
+	Properties []tmjProperty `json:"properties"`
+}
+
+type tmjTileset struct {
+	FirstGID   int       `json:"firstgid"`
+	Name       string    `json:"name"`
+	TileWidth  int       `json:"tilewidth"`
+	TileHeight int       `json:"tileheight"`
+	TileCount  int       `json:"tilecount"`
+	Columns    int       `json:"columns"`
+	Margin     int       `json:"margin"`
+	Spacing    int       `json:"spacing"`
+	Grid       tmxGrid   `json:"grid"`
+	Tiles      []tmjTile `json:"tiles"`
+}
+
+type tmjLayer struct {
+	ID      int     `json:"id"`
+	Name    string  `json:"name"`
+	Type    string  `json:"type"`
+	Width   int     `json:"width"`
+	Height  int     `json:"height"`
+	X       int     `json:"x"`
+	Y       int     `json:"y"`
+	Opacity float64 `json:"opacity"`
+	Visible bool    `json:"visible"`
+	Data    []int   `json:"data"`
+}
+
+type tmjMap struct {
+	Type         string        `json:"type"`
+	Version      string        `json:"version"`
+	RenderOrder  string        `json:"renderorder"`
+	Width        int           `json:"width"`
+	Height       int           `json:"height"`
+	TileWidth    int           `json:"tilewidth"`
+	TileHeight   int           `json:"tileheight"`
+	Infinite     bool          `json:"infinite"`
+	NextLayerID  int           `json:"nextlayerid"`
+	NextObjectID int           `json:"nextobjectid"`
+	Properties   []tmjProperty `json:"properties"`
+	Tilesets     []tmjTileset  `json:"tilesets"`
+	Layers       []tmjLayer    `json:"layers"`
+
+	tiledShape
+}
+
+func tmjProperties(props []tmxProperty) []tmjProperty {
+	out := make([]tmjProperty, len(props))
+	for i, p := range props {
+		out[i] = tmjProperty{Name: p.Name, Type: p.Type, Value: p.Value}
+		if out[i].Type == "" {
+			out[i].Type = "string"
+		}
+	}
+	return out
+}
+
+// TMJ returns a writer for Tiled's JSON map format.
+func TMJ(scale int) Writer {
+	size := tileSize(scale)
+	return func(w io.Writer, m *Map) error {
+		ts := tmjTileset{
+			FirstGID:   1,
+			Name:       m.Kind,
+			TileWidth:  size,
+			TileHeight: size,
+			TileCount:  len(m.Legend),
+			Grid:       tmxGrid{Orientation: "orthogonal", Width: 1, Height: 1},
+		}
+		for i, c := range m.Legend {
+			ts.Tiles = append(ts.Tiles, tmjTile{ID: i, Type: c.Name, Properties: tmjProperties(tileProperties(c))})
+		}
+		out := tmjMap{
+			Type:         "map",
+			Version:      tiledVersion,
+			tiledShape:   shape(m, size),
+			RenderOrder:  "right-down",
+			Width:        m.Width,
+			Height:       m.Height,
+			TileWidth:    size,
+			TileHeight:   size,
+			NextLayerID:  2,
+			NextObjectID: 1,
+			Properties:   tmjProperties(mapProperties(m)),
+			Tilesets:     []tmjTileset{ts},
+			Layers: []tmjLayer{{
+				ID: 1, Name: "tiles", Type: "tilelayer",
+				Width: m.Width, Height: m.Height,
+				Opacity: 1, Visible: true,
+				Data: tiledGIDs(m),
+			}},
+		}
+		return json.NewEncoder(w).Encode(out)
+	}
+}
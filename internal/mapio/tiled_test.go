@@ -0,0 +1,68 @@
+package mapio
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// sampleMap is a small map using every kind of cell the writers handle:
+// two legend tiles, a linked road, an unresolved cell and a label.
+func sampleMap(hex, wrap bool) *Map {
+	water := Cell{Name: "water", Glyph: "~", Color: "33"}
+	land := Cell{Name: "land", Glyph: "█", Color: "185"}
+	road := Cell{Name: "road", Glyph: "═", Color: "240", Links: LinkLeft | LinkRight}
+	return &Map{
+		Kind:   "land",
+		Width:  3,
+		Height: 2,
+		Seed:   7,
+		Hex:    hex,
+		Wrap:   wrap,
+		Cells: [][]Cell{
+			{water, land, road},
+			{land, Unresolved, water},
+		},
+		Legend: []Cell{water, land, road},
+		Labels: []Label{{Text: "Isle", Kind: "continent", X: 1, Y: 0}},
+	}
+}
+
+func TestTiledGolden(t *testing.T) {
+	tests := []struct {
+		name  string
+		write Writer
+		m     *Map
+	}{
+		{"square.tmx", TMX(8), sampleMap(false, false)},
+		{"hex.tmx", TMX(8), sampleMap(true, false)},
+		{"wrap.tmx", TMX(8), sampleMap(false, true)},
+		{"square.tmj", TMJ(8), sampleMap(false, false)},
+		{"hex.tmj", TMJ(8), sampleMap(true, false)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := tt.write(&buf, tt.m); err != nil {
+				t.Fatal(err)
+			}
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, buf.Bytes(), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(buf.Bytes(), want) {
+				t.Errorf("output differs from %s:\n%s", golden, buf.String())
+			}
+		})
+	}
+}
@@ -5,24 +5,27 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"atlas.games/internal/registry"
 )
 
 var (
 	titleStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true).Underline(true)
 	itemStyle  = lipgloss.NewStyle().PaddingLeft(2)
 	selectedItemStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("214")).Bold(true)
+	categoryStyle = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("244")).Bold(true)
+	descStyle     = lipgloss.NewStyle().PaddingLeft(2).Foreground(lipgloss.Color("244")).Italic(true)
 )
 
 type Model struct {
 	cursor   int
-	items    []string
+	items    []registry.Game
 	choice   string
 	quitting bool
 }
 
 func NewModel() Model {
 	return Model{
-		items: []string{"Wilson's Revenge", "WFC Land Creator", "WFC City Generator", "Tactical Colony", "Atlas Warlord", "Atlas Defense", "Atlas Breach", "Exit"},
+		items: registry.All(),
 	}
 }
 
@@ -42,11 +45,14 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 				m.cursor--
 			}
 		case "down", "j":
-			if m.cursor < len(m.items)-1 {
+			// The extra slot past the last game is "Exit"
+			if m.cursor < len(m.items) {
 				m.cursor++
 			}
 		case "enter", " ":
-			m.choice = m.items[m.cursor]
+			if m.cursor < len(m.items) {
+				m.choice = m.items[m.cursor].ID
+			}
 			return m, tea.Quit
 		}
 	}
@@ -59,20 +65,37 @@ func (m Model) View() string {
 	}
 
 	var sb strings.Builder
-	sb.WriteString("\n\n  " + titleStyle.Render(" ATLAS GAMES ARCHIVE ") + "\n\n")
+	sb.WriteString("\n\n  " + titleStyle.Render(" ATLAS GAMES ARCHIVE ") + "\n")
 
+	category := ""
 	for i, item := range m.items {
+		if item.Category != category {
+			category = item.Category
+			sb.WriteString("\n" + categoryStyle.Render(strings.ToUpper(category)) + "\n")
+		}
 		if m.cursor == i {
-			sb.WriteString(selectedItemStyle.Render("> " + item) + "\n")
+			sb.WriteString(selectedItemStyle.Render("> " + item.Title) + "\n")
 		} else {
-			sb.WriteString(itemStyle.Render("  " + item) + "\n")
+			sb.WriteString(itemStyle.Render("  " + item.Title) + "\n")
 		}
 	}
 
+	sb.WriteString("\n")
+	if m.cursor == len(m.items) {
+		sb.WriteString(selectedItemStyle.Render("> Exit") + "\n")
+	} else {
+		sb.WriteString(itemStyle.Render("  Exit") + "\n")
+	}
+
+	if m.cursor < len(m.items) {
+		sb.WriteString("\n" + descStyle.Render(m.items[m.cursor].Description) + "\n")
+	}
+
 	sb.WriteString("\n  [↑/↓] Navigate  [Enter] Select  [Q] Quit\n")
 	return sb.String()
 }
 
+// Choice returns the ID of the selected game, or "" when the user exits.
 func (m Model) Choice() string {
 	return m.choice
 }
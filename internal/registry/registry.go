@@ -0,0 +1,78 @@
+package registry
+
+import (
+	"sort"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Categories in the order the launcher lists them.
+const (
+	Arcade     = "Arcade"
+	Simulation = "Simulation"
+	Strategy   = "Strategy"
+	Generators = "Generators"
+)
+
+var categoryOrder = []string{Arcade, Simulation, Strategy, Generators}
+
+// Game describes a launchable entry in the archive.
+type Game struct {
+	ID          string
+	Title       string
+	Description string
+	Category    string
+	Order       int                        // Position inside the category, lower first
+	New         func(seed int64) tea.Model // seed drives every random choice the game makes
+}
+
+var games = map[string]Game{}
+
+// Register adds a game to the launcher. Packages call it from init().
+func Register(g Game) {
+	if g.ID == "" || g.New == nil {
+		panic("registry: game needs an ID and a constructor")
+	}
+	if _, dup := games[g.ID]; dup {
+		panic("registry: duplicate game ID " + g.ID)
+	}
+	games[g.ID] = g
+}
+
+// Lookup returns the game registered under id.
+func Lookup(id string) (Game, bool) {
+	g, ok := games[id]
+	return g, ok
+}
+
+// All returns every registered game sorted by category, order and title.
+// Unknown categories are listed after the built-in ones.
+func All() []Game {
+	list := make([]Game, 0, len(games))
+	for _, g := range games {
+		list = append(list, g)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		a, b := list[i], list[j]
+		if ca, cb := categoryRank(a.Category), categoryRank(b.Category); ca != cb {
+			return ca < cb
+		}
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		if a.Order != b.Order {
+			return a.Order < b.Order
+		}
+		return a.Title < b.Title
+	})
+	return list
+}
+
+func categoryRank(c string) int {
+	for i, known := range categoryOrder {
+		if c == known {
+			return i
+		}
+	}
+	return len(categoryOrder)
+}
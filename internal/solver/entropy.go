@@ -0,0 +1,93 @@
+package solver
+
+import (
+	"container/heap"
+	"math"
+)
+
+// cellEntry is a queued cell. ver must match the cell's current version,
+// otherwise the cell changed after it was queued and the entry is stale.
+type cellEntry struct {
+	rank float64
+	idx  int32
+	ver  uint32
+}
+
+// entropyHeap is a min-heap of cells keyed on rank. Entries are never
+// updated in place; a changed cell is pushed again and the old entry is
+// skipped when it surfaces.
+type entropyHeap []cellEntry
+
+func (h entropyHeap) Len() int            { return len(h) }
+func (h entropyHeap) Less(i, j int) bool  { return h[i].rank < h[j].rank }
+func (h entropyHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *entropyHeap) Push(x interface{}) { *h = append(*h, x.(cellEntry)) }
+func (h *entropyHeap) Pop() interface{} {
+	old := *h
+	e := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return e
+}
+
+// rank is the heap key of a cell with possibilities ps: their count, plus
+// a random fraction so cells with the same count come up in random order.
+// Weighted entropy would split them too finely, always taking some tile
+// pairs first and squeezing the rest off the map, so it is left to the
+// heat maps.
+func (s *Solver[T]) rank(ps []T) float64 {
+	return float64(len(ps)) + s.rng.Float64()
+}
+
+// shannon is the weighted Shannon entropy of a set of possibilities:
+// log(sum w) - sum(w log w) / sum w.
+func (s *Solver[T]) shannon(ps []T) float64 {
+	sum, sumLog := 0.0, 0.0
+	for _, p := range ps {
+		wt := float64(s.weightOf[p])
+		sum += wt
+		sumLog += wt * math.Log(wt)
+	}
+	if sum == 0 {
+		return 0
+	}
+	return math.Log(sum) - sumLog/sum
+}
+
+// GetEntropy returns the weighted Shannon entropy of (x, y). Collapsed cells
+// report +Inf so they never look like the next cell to observe.
+func (s *Solver[T]) GetEntropy(x, y int) float64 {
+	tile := s.Grid[y][x]
+	if tile.Collapsed {
+		return math.Inf(1)
+	}
+	return s.shannon(tile.Possibilities)
+}
+
+// enqueue (re)queues an uncollapsed cell under its current rank.
+func (s *Solver[T]) enqueue(x, y int) {
+	tile := s.Grid[y][x]
+	if tile.Collapsed {
+		return
+	}
+	idx := y*s.Width + x
+	heap.Push(&s.queue, cellEntry{
+		rank: s.rank(tile.Possibilities),
+		idx:  int32(idx),
+		ver:  s.versions[idx],
+	})
+}
+
+// nextCell pops the uncollapsed cell with the fewest possibilities. ok is
+// false once every cell has collapsed.
+func (s *Solver[T]) nextCell() (x, y int, ok bool) {
+	for s.queue.Len() > 0 {
+		e := heap.Pop(&s.queue).(cellEntry)
+		idx := int(e.idx)
+		x, y = idx%s.Width, idx/s.Width
+		if e.ver != s.versions[idx] || s.Grid[y][x].Collapsed {
+			continue
+		}
+		return x, y, true
+	}
+	return 0, 0, false
+}
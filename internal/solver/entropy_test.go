@@ -0,0 +1,86 @@
+package solver
+
+import (
+	"container/heap"
+	"slices"
+	"testing"
+)
+
+// free is a rule set of n tiles of equal weight that may sit anywhere.
+func free(n int) Rules[int] {
+	tiles := make([]int, n)
+	for i := range tiles {
+		tiles[i] = i
+	}
+	return Rules[int]{
+		Tiles:  tiles,
+		Weight: func(int) int { return 1 },
+		Allows: func(a, b, dir int) bool { return true },
+	}
+}
+
+func TestEntropyHeapOrder(t *testing.T) {
+	tests := []struct {
+		name  string
+		ranks []float64
+	}{
+		{"sorted", []float64{1, 2, 3}},
+		{"reversed", []float64{3.5, 2.5, 1.5}},
+		{"mixed", []float64{4.2, 1.9, 3.1, 1.1, 2.7}},
+		{"ties", []float64{2, 1, 2, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &entropyHeap{}
+			for i, r := range tt.ranks {
+				heap.Push(h, cellEntry{rank: r, idx: int32(i)})
+			}
+			var got []float64
+			for h.Len() > 0 {
+				got = append(got, heap.Pop(h).(cellEntry).rank)
+			}
+			want := slices.Clone(tt.ranks)
+			slices.Sort(want)
+			if !slices.Equal(got, want) {
+				t.Errorf("popped %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestNextCellFewestPossibilities(t *testing.T) {
+	tests := []struct {
+		name     string
+		restrict map[[2]int]int // Cell to the number of tiles it keeps
+		want     [2]int
+	}{
+		{"one narrowed", map[[2]int]int{{2, 1}: 2}, [2]int{2, 1}},
+		{"narrowest wins", map[[2]int]int{{0, 0}: 3, {3, 2}: 2, {1, 3}: 3}, [2]int{3, 2}},
+		{"narrowed twice", map[[2]int]int{{1, 1}: 2, {2, 2}: 3}, [2]int{1, 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(4, 4, 1, nil, free(4))
+			for c, n := range tt.restrict {
+				s.Restrict(c[0], c[1], func(t int) bool { return t < n })
+			}
+			x, y, ok := s.nextCell()
+			if !ok || [2]int{x, y} != tt.want {
+				t.Errorf("nextCell() = %d, %d, %v, want %v", x, y, ok, tt.want)
+			}
+		})
+	}
+}
+
+// TestTiesRandom checks that cells with the same number of possibilities
+// are picked in random order rather than in scan order.
+func TestTiesRandom(t *testing.T) {
+	firsts := map[[2]int]bool{}
+	for seed := int64(1); seed <= 20; seed++ {
+		x, y, _ := New(8, 8, seed, nil, free(3)).nextCell()
+		firsts[[2]int{x, y}] = true
+	}
+	if len(firsts) < 10 {
+		t.Errorf("20 seeds picked only %d different first cells", len(firsts))
+	}
+}
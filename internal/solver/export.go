@@ -0,0 +1,44 @@
+package solver
+
+import "atlas.games/internal/mapio"
+
+// Export snapshots the grid for the writers in mapio, using cell to look up
+// each tile's palette entry. Cells that have not collapsed yet are reported
+// as mapio.Unresolved. Tiles that share a name appear once in the legend.
+func (s *Solver[T]) Export(kind string, cell func(T) mapio.Cell) *mapio.Map {
+	m := &mapio.Map{
+		Kind:   kind,
+		Width:  s.Width,
+		Height: s.Height,
+		Seed:   s.Seed,
+		Cells:  make([][]mapio.Cell, s.Height),
+	}
+	switch t := s.Topology.(type) {
+	case Square:
+		m.Wrap = t.Wrap
+	case Moore:
+		m.Wrap = t.Wrap
+	case Hex:
+		m.Hex, m.Wrap = true, t.Wrap
+	}
+	seen := map[string]bool{}
+	for _, t := range s.rules.Tiles {
+		c := cell(t)
+		if !seen[c.Name] {
+			seen[c.Name] = true
+			m.Legend = append(m.Legend, c)
+		}
+	}
+	for y := 0; y < s.Height; y++ {
+		m.Cells[y] = make([]mapio.Cell, s.Width)
+		for x := 0; x < s.Width; x++ {
+			tile := s.Grid[y][x]
+			if !tile.Collapsed {
+				m.Cells[y][x] = mapio.Unresolved
+				continue
+			}
+			m.Cells[y][x] = cell(tile.Type)
+		}
+	}
+	return m
+}
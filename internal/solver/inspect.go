@@ -0,0 +1,98 @@
+package solver
+
+import (
+	"math"
+	"sort"
+)
+
+// Candidate is a tile a cell can still collapse to, weighted the way
+// Collapse would weigh it.
+type Candidate[T ~int] struct {
+	Tile      T
+	Weight    int     // From the rules, after any Bias
+	Bonuses   int     // Collapsed neighbors of the same tile, each multiplying by NeighborBonus
+	Effective int     // Weight after bonuses
+	Chance    float64 // Share of the cell's total effective weight
+}
+
+// Inspection is what the solver knows about one cell.
+type Inspection[T ~int] struct {
+	X, Y       int
+	Collapsed  bool
+	Tile       T
+	Entropy    float64 // Weighted Shannon entropy, +Inf once collapsed
+	Candidates []Candidate[T]
+}
+
+// Inspect describes (x, y) on the live grid.
+func (s *Solver[T]) Inspect(x, y int) Inspection[T] {
+	return s.inspect(s.Grid, x, y)
+}
+
+// Inspect describes (x, y) as the view shows it.
+func (tl *Timeline[T]) Inspect(x, y int) Inspection[T] {
+	return tl.s.inspect(tl.grid, x, y)
+}
+
+// MaxEntropy is the entropy of a cell that could still be any tile, for
+// scaling entropy to a 0..1 range.
+func (s *Solver[T]) MaxEntropy() float64 {
+	return s.shannon(s.rules.Tiles)
+}
+
+// inspect lists the candidates of (x, y) on grid, most likely first.
+func (s *Solver[T]) inspect(grid [][]Tile[T], x, y int) Inspection[T] {
+	tile := grid[y][x]
+	in := Inspection[T]{X: x, Y: y, Collapsed: tile.Collapsed, Tile: tile.Type, Entropy: math.Inf(1)}
+	if !tile.Collapsed {
+		in.Entropy = s.shannon(tile.Possibilities)
+	}
+
+	bonuses := map[T]int{}
+	if s.rules.NeighborBonus > 1 {
+		for d := 0; d < s.Topology.Dirs(); d++ {
+			nx, ny, ok := s.Topology.Step(x, y, d, s.Width, s.Height)
+			if ok && grid[ny][nx].Collapsed {
+				bonuses[grid[ny][nx].Type]++
+			}
+		}
+	}
+
+	total := 0
+	for _, p := range tile.Possibilities {
+		w := s.weightAt(x, y, p)
+		c := Candidate[T]{Tile: p, Weight: w, Effective: w}
+		if c.Weight > 0 {
+			c.Bonuses = bonuses[p]
+			for i := 0; i < c.Bonuses; i++ {
+				c.Effective *= s.rules.NeighborBonus
+			}
+		}
+		total += c.Effective
+		in.Candidates = append(in.Candidates, c)
+	}
+	for i := range in.Candidates {
+		if total > 0 {
+			in.Candidates[i].Chance = float64(in.Candidates[i].Effective) / float64(total)
+		}
+	}
+	sort.SliceStable(in.Candidates, func(i, j int) bool {
+		return in.Candidates[i].Effective > in.Candidates[j].Effective
+	})
+	return in
+}
+
+// Entropy is the entropy of (x, y) as the view shows it; see GetEntropy.
+func (tl *Timeline[T]) Entropy(x, y int) float64 {
+	tile := tl.grid[y][x]
+	if tile.Collapsed {
+		return math.Inf(1)
+	}
+	return tl.s.shannon(tile.Possibilities)
+}
+
+// NumTiles is how many tiles the rules have, the most possibilities a
+// cell can hold.
+func (s *Solver[T]) NumTiles() int {
+	return len(s.rules.Tiles)
+}
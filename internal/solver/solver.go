@@ -0,0 +1,487 @@
+// Package solver is the Wave Function Collapse engine shared by the map
+// generators. A generator supplies its tiles, weights and adjacency rules;
+// the solver handles entropy selection, propagation, backtracking and
+// restarts.
+package solver
+
+import (
+	"container/heap"
+	"math/rand"
+	"slices"
+)
+
+// Tile is one grid cell. Possibilities slices may be shared between cells
+// and must be replaced, never modified in place.
+type Tile[T ~int] struct {
+	Type          T
+	Possibilities []T
+	Collapsed     bool
+}
+
+// Rules is a tile set as seen by the solver. Tile values are used as table
+// indexes, so they should be small non-negative integers.
+type Rules[T ~int] struct {
+	Tiles  []T
+	Weight func(t T) int
+	// Allows reports whether b may sit in direction dir of a.
+	Allows func(a, b T, dir int) bool
+	// NeighborBonus multiplies the weight of a candidate that matches an
+	// already collapsed neighbor, which grows tiles into patches. Values
+	// below 2 disable it.
+	NeighborBonus int
+	// Bias, if set, scales the weight of t at (x, y) by a percentage, for
+	// rule sets whose odds change across the map. Entropy still uses the
+	// plain weights.
+	Bias func(x, y int, t T) int
+	// Seed, if set, pins starting tiles after every reset. Pins made here
+	// are permanent.
+	Seed func(s *Solver[T])
+}
+
+// Backtracking limits. undoDepth is how many recent collapses can be rolled
+// back; backtrackBudget is how many rollbacks one attempt may spend before
+// the solver gives up on it and restarts from a fresh seeding.
+const (
+	undoDepth       = 64
+	backtrackBudget = 2000
+)
+
+// change is the state of a cell before the solver touched it.
+type change[T ~int] struct {
+	x, y int
+	tile Tile[T]
+}
+
+// decision is a collapse the solver may later take back.
+type decision[T ~int] struct {
+	x, y   int
+	choice T
+	mark   int // Absolute trail position before the collapse
+}
+
+type Solver[T ~int] struct {
+	Width    int
+	Height   int
+	Grid     [][]Tile[T]
+	Seed     int64
+	Topology Topology
+
+	Backtracks int // Rollbacks across all attempts
+	Restarts   int // Attempts abandoned after exhausting the budget
+
+	rules    Rules[T]
+	rng      *rand.Rand
+	stride   int      // Largest tile value + 1
+	allow    [][]bool // allow[dir][a*stride+b], see Rules.Allows
+	weightOf []int    // Indexed by tile
+
+	trail     []change[T]
+	trailBase int // Absolute position of trail[0]
+	decisions []decision[T]
+	spent     int // Rollbacks used by the current attempt
+
+	queue    entropyHeap
+	versions []uint32 // Bumped on every change to a cell, see cellEntry
+
+	// Propagation scratch space, reused between calls
+	pending []int32
+	queued  []bool
+	weights []int
+
+	timeline *Timeline[T] // Set by Record
+}
+
+// New prepares a width x height grid. A nil topology selects Square.
+func New[T ~int](width, height int, seed int64, topo Topology, rules Rules[T]) *Solver[T] {
+	if topo == nil {
+		topo = Square{}
+	}
+	s := &Solver[T]{
+		Width:    width,
+		Height:   height,
+		Seed:     seed,
+		Topology: topo,
+		rules:    rules,
+		rng:      rand.New(rand.NewSource(seed)),
+	}
+	s.compile()
+	s.reset()
+	return s
+}
+
+// compile turns the rule callbacks into lookup tables.
+func (s *Solver[T]) compile() {
+	for _, t := range s.rules.Tiles {
+		if int(t)+1 > s.stride {
+			s.stride = int(t) + 1
+		}
+	}
+	s.weightOf = make([]int, s.stride)
+	for _, t := range s.rules.Tiles {
+		s.weightOf[t] = s.rules.Weight(t)
+	}
+	s.allow = make([][]bool, s.Topology.Dirs())
+	for d := range s.allow {
+		s.allow[d] = make([]bool, s.stride*s.stride)
+		for _, a := range s.rules.Tiles {
+			for _, b := range s.rules.Tiles {
+				s.allow[d][int(a)*s.stride+int(b)] = s.rules.Allows(a, b, d)
+			}
+		}
+	}
+}
+
+// Rand is the solver's random source, for use by Rules.Seed.
+func (s *Solver[T]) Rand() *rand.Rand {
+	return s.rng
+}
+
+// reset clears the grid and runs the seeding rule.
+func (s *Solver[T]) reset() {
+	old := s.Grid
+	s.Grid = make([][]Tile[T], s.Height)
+	s.trail, s.trailBase, s.decisions, s.spent = nil, 0, nil, 0
+
+	cells := s.Width * s.Height
+	s.versions = make([]uint32, cells)
+	s.queued = make([]bool, cells)
+	s.weights = make([]int, s.stride)
+	s.queue = make(entropyHeap, 0, cells)
+
+	allTypes := append([]T(nil), s.rules.Tiles...)
+
+	for y := 0; y < s.Height; y++ {
+		s.Grid[y] = make([]Tile[T], 0, s.Width)
+		for x := 0; x < s.Width; x++ {
+			s.Grid[y] = append(s.Grid[y], Tile[T]{
+				Possibilities: allTypes,
+				Collapsed:     false,
+			})
+		}
+	}
+
+	// Every cell starts with every tile, so the heap can be filled
+	// directly and ordered once
+	for i := 0; i < cells; i++ {
+		s.queue = append(s.queue, cellEntry{rank: s.rank(allTypes), idx: int32(i)})
+	}
+	heap.Init(&s.queue)
+
+	if tl := s.timeline; tl != nil {
+		tl.frame().Restarted = true
+		for y := range old {
+			for x := range old[y] {
+				tl.record(x, y, old[y][x], s.Grid[y][x])
+			}
+		}
+	}
+
+	if s.rules.Seed != nil {
+		s.rules.Seed(s)
+	}
+
+	// Seeds are permanent, nothing before this point can be undone
+	s.trail, s.trailBase = nil, 0
+}
+
+// Pin collapses (x, y) to t, dropping the pin again if it clashes with what
+// is already on the grid. It reports whether the pin was kept.
+func (s *Solver[T]) Pin(x, y int, t T) bool {
+	tile := s.Grid[y][x]
+	if tile.Collapsed || !slices.Contains(tile.Possibilities, t) {
+		return false
+	}
+	mark := s.mark()
+	s.set(x, y, Tile[T]{Type: t, Collapsed: true, Possibilities: []T{t}})
+	if !s.Propagate(x, y) {
+		s.undo(mark)
+		return false
+	}
+	return true
+}
+
+// weightAt is the weight of t at (x, y) after Rules.Bias. A biased tile
+// keeps a weight of at least 1, so it is never ruled out.
+func (s *Solver[T]) weightAt(x, y int, t T) int {
+	w := s.weightOf[t]
+	if s.rules.Bias == nil || w == 0 {
+		return w
+	}
+	return max(1, w*s.rules.Bias(x, y, t)/100)
+}
+
+// Overwrite replaces (x, y) with a collapsed t without checking the rules,
+// for passes that edit a finished grid. The change is recorded like any
+// other but backtracking cannot take it back.
+func (s *Solver[T]) Overwrite(x, y int, t T) {
+	s.set(x, y, Tile[T]{Type: t, Collapsed: true, Possibilities: []T{t}})
+}
+
+// Restart abandons the grid and starts over from a fresh seeding, for
+// generators whose finished maps fail a check of their own. It does not
+// count towards Restarts.
+func (s *Solver[T]) Restart() {
+	s.reset()
+}
+
+// Restrict narrows (x, y) to the possibilities keep accepts. Like Pin, it
+// drops the restriction again if it leads to a contradiction, and reports
+// whether it was kept.
+func (s *Solver[T]) Restrict(x, y int, keep func(t T) bool) bool {
+	tile := s.Grid[y][x]
+	narrowed := make([]T, 0, len(tile.Possibilities))
+	for _, p := range tile.Possibilities {
+		if keep(p) {
+			narrowed = append(narrowed, p)
+		}
+	}
+	if len(narrowed) == len(tile.Possibilities) {
+		return true
+	}
+	if len(narrowed) == 0 {
+		return false
+	}
+	mark := s.mark()
+	tile.Possibilities = narrowed
+	s.set(x, y, tile)
+	if !s.Propagate(x, y) {
+		s.undo(mark)
+		return false
+	}
+	return true
+}
+
+// Collapse observes the cell with the fewest possibilities left. It returns
+// true once the grid is fully collapsed.
+func (s *Solver[T]) Collapse() bool {
+	x, y, ok := s.nextCell()
+	if !ok {
+		return true
+	}
+
+	tile := &s.Grid[y][x]
+	if len(tile.Possibilities) == 0 {
+		s.backtrack()
+		return false
+	}
+
+	typeWeights := s.weights
+	for _, p := range tile.Possibilities {
+		typeWeights[p] = s.weightAt(x, y, p)
+	}
+
+	// Neighbor Bonus
+	if s.rules.NeighborBonus > 1 {
+		for d := 0; d < s.Topology.Dirs(); d++ {
+			nx, ny, ok := s.Topology.Step(x, y, d, s.Width, s.Height)
+			if !ok {
+				continue
+			}
+			neighbor := s.Grid[ny][nx]
+			if neighbor.Collapsed && typeWeights[neighbor.Type] > 0 {
+				typeWeights[neighbor.Type] *= s.rules.NeighborBonus
+			}
+		}
+	}
+
+	totalWeight := 0
+	for _, p := range tile.Possibilities {
+		totalWeight += typeWeights[p]
+	}
+	// Only zero-weight tiles are left, which are never picked
+	if totalWeight == 0 {
+		s.backtrack()
+		return false
+	}
+
+	pick := s.rng.Intn(totalWeight)
+	current := 0
+	var selected T
+	for _, p := range tile.Possibilities {
+		current += typeWeights[p]
+		if pick < current {
+			selected = p
+			break
+		}
+	}
+	for _, p := range tile.Possibilities {
+		typeWeights[p] = 0
+	}
+
+	if s.timeline != nil {
+		s.timeline.observe(x, y, selected)
+	}
+	s.pushDecision(x, y, selected)
+	s.set(x, y, Tile[T]{Type: selected, Collapsed: true, Possibilities: []T{selected}})
+
+	if !s.Propagate(x, y) {
+		s.backtrack()
+	}
+	return false
+}
+
+// Propagate narrows the neighbors of (x, y) until the grid is consistent
+// again. It returns false if some cell is left with no possibilities.
+func (s *Solver[T]) Propagate(x, y int) bool {
+	start := int32(y*s.Width + x)
+	s.pending = append(s.pending[:0], start)
+	s.queued[start] = true
+
+	head := 0
+	ok := true
+	for ok && head < len(s.pending) {
+		idx := int(s.pending[head])
+		head++
+		s.queued[idx] = false
+		cx, cy := idx%s.Width, idx/s.Width
+		current := s.Grid[cy][cx].Possibilities
+
+		for d := 0; d < s.Topology.Dirs(); d++ {
+			nx, ny, inside := s.Topology.Step(cx, cy, d, s.Width, s.Height)
+			if !inside {
+				continue
+			}
+
+			before := s.Grid[ny][nx].Possibilities
+			kept := 0
+			for _, q := range before {
+				if s.supported(current, q, d) {
+					kept++
+				}
+			}
+			if kept == len(before) {
+				continue
+			}
+
+			newPossibilities := make([]T, 0, kept)
+			for _, q := range before {
+				if s.supported(current, q, d) {
+					newPossibilities = append(newPossibilities, q)
+				}
+			}
+
+			// Collapsed cells are checked too, so a clash with a
+			// pinned tile shows up as a contradiction.
+			next := s.Grid[ny][nx]
+			next.Possibilities = newPossibilities
+			s.set(nx, ny, next)
+			if kept == 0 {
+				ok = false
+				break
+			}
+			nidx := ny*s.Width + nx
+			if !s.queued[nidx] {
+				s.pending = append(s.pending, int32(nidx))
+				s.queued[nidx] = true
+			}
+		}
+	}
+
+	for _, idx := range s.pending[head:] {
+		s.queued[idx] = false
+	}
+	return ok
+}
+
+// supported reports whether q may sit in direction dir of at least one of ps.
+func (s *Solver[T]) supported(ps []T, q T, dir int) bool {
+	allow := s.allow[dir]
+	for _, p := range ps {
+		if allow[int(p)*s.stride+int(q)] {
+			return true
+		}
+	}
+	return false
+}
+
+// set replaces a cell, remembering its old state on the trail.
+func (s *Solver[T]) set(x, y int, t Tile[T]) {
+	if s.timeline != nil {
+		s.timeline.record(x, y, s.Grid[y][x], t)
+	}
+	s.trail = append(s.trail, change[T]{x: x, y: y, tile: s.Grid[y][x]})
+	s.Grid[y][x] = t
+	s.touch(x, y)
+}
+
+// touch invalidates queued entries of (x, y) and requeues it.
+func (s *Solver[T]) touch(x, y int) {
+	s.versions[y*s.Width+x]++
+	s.enqueue(x, y)
+}
+
+func (s *Solver[T]) mark() int {
+	return s.trailBase + len(s.trail)
+}
+
+// undo restores every cell changed since mark.
+func (s *Solver[T]) undo(mark int) {
+	for s.mark() > mark {
+		c := s.trail[len(s.trail)-1]
+		s.trail = s.trail[:len(s.trail)-1]
+		if s.timeline != nil {
+			s.timeline.record(c.x, c.y, s.Grid[c.y][c.x], c.tile)
+		}
+		s.Grid[c.y][c.x] = c.tile
+		s.touch(c.x, c.y)
+	}
+}
+
+// pushDecision records a collapse, forgetting the oldest one once the undo
+// stack is full.
+func (s *Solver[T]) pushDecision(x, y int, choice T) {
+	s.decisions = append(s.decisions, decision[T]{x: x, y: y, choice: choice, mark: s.mark()})
+	if len(s.decisions) > undoDepth {
+		s.decisions = s.decisions[1:]
+		// Reslicing is enough: append drops the dead prefix when it grows
+		cut := s.decisions[0].mark - s.trailBase
+		s.trail = s.trail[cut:]
+		s.trailBase += cut
+	}
+}
+
+// backtrack rolls back the most recent collapse and bans the tile it chose,
+// walking further back while that still leaves a contradiction. When the
+// undo stack or the budget runs out the whole map is started over.
+func (s *Solver[T]) backtrack() {
+	for {
+		if len(s.decisions) == 0 || s.spent >= backtrackBudget {
+			s.Restarts++
+			s.reset()
+			return
+		}
+
+		d := s.decisions[len(s.decisions)-1]
+		s.decisions = s.decisions[:len(s.decisions)-1]
+		if s.timeline != nil {
+			s.timeline.frame().Backtracked = true
+		}
+		s.undo(d.mark)
+		s.Backtracks++
+		s.spent++
+
+		tile := s.Grid[d.y][d.x]
+		remaining := []T{}
+		for _, p := range tile.Possibilities {
+			if p != d.choice {
+				remaining = append(remaining, p)
+			}
+		}
+		tile.Possibilities = remaining
+		s.set(d.x, d.y, tile)
+
+		if len(remaining) > 0 && s.Propagate(d.x, d.y) {
+			return
+		}
+	}
+}
+
+// Step advances the solver by one collapse. It returns true when done.
+func (s *Solver[T]) Step() bool {
+	if s.timeline == nil {
+		return s.Collapse()
+	}
+	s.timeline.begin()
+	defer s.timeline.end()
+	return s.Collapse()
+}
@@ -0,0 +1,185 @@
+package solver
+
+import "testing"
+
+// colors is a rule set of n tiles where neighbors must differ, which greedy
+// collapsing often paints into a corner.
+func colors(n int) Rules[int] {
+	r := free(n)
+	r.Allows = func(a, b, dir int) bool { return a != b }
+	return r
+}
+
+// solve steps s until it finishes or has taken limit steps, and reports
+// whether it finished.
+func solve[T ~int](s *Solver[T], limit int) bool {
+	for i := 0; i < limit; i++ {
+		if s.Step() {
+			return true
+		}
+	}
+	return false
+}
+
+// valid reports whether every cell of a finished grid is collapsed and
+// allowed next to each of its neighbors.
+func valid[T ~int](s *Solver[T]) bool {
+	for y := 0; y < s.Height; y++ {
+		for x := 0; x < s.Width; x++ {
+			a := s.Grid[y][x]
+			if !a.Collapsed {
+				return false
+			}
+			for d := 0; d < s.Topology.Dirs(); d++ {
+				nx, ny, ok := s.Topology.Step(x, y, d, s.Width, s.Height)
+				if ok && !s.rules.Allows(a.Type, s.Grid[ny][nx].Type, d) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+func TestBacktrackRecovers(t *testing.T) {
+	backtracks := 0
+	for seed := int64(1); seed <= 20; seed++ {
+		s := New(12, 12, seed, nil, colors(3))
+		if !solve(s, 100000) {
+			t.Fatalf("seed %d: not finished after %d backtracks and %d restarts", seed, s.Backtracks, s.Restarts)
+		}
+		if !valid(s) {
+			t.Fatalf("seed %d: finished grid breaks the rules", seed)
+		}
+		backtracks += s.Backtracks
+	}
+	if backtracks == 0 {
+		t.Error("no seed needed to backtrack, so the test shows nothing")
+	}
+}
+
+func TestRestartsWhenUnsatisfiable(t *testing.T) {
+	tests := []struct {
+		name string
+		topo Topology
+		w, h int
+	}{
+		// Two colors cannot alternate around an odd ring
+		{"odd ring", Square{Wrap: true}, 3, 2},
+		{"odd wrapped square", Square{Wrap: true}, 5, 5},
+		// Every hex cell sits in a triangle of neighbors
+		{"hex", Hex{}, 3, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := New(tt.w, tt.h, 1, tt.topo, colors(2))
+			if solve(s, 50000) {
+				t.Fatal("finished a grid that has no solution")
+			}
+			if s.Restarts == 0 {
+				t.Errorf("no restarts after %d backtracks", s.Backtracks)
+			}
+		})
+	}
+}
+
+func TestPinClash(t *testing.T) {
+	s := New(3, 1, 1, nil, colors(2))
+	if !s.Pin(0, 0, 0) {
+		t.Fatal("first pin rejected")
+	}
+	if s.Pin(1, 0, 0) {
+		t.Error("pin next to the same color kept")
+	}
+	if s.Grid[0][1].Collapsed {
+		t.Error("rejected pin left its cell collapsed")
+	}
+	if !s.Pin(2, 0, 0) {
+		t.Error("pin two cells away rejected")
+	}
+	if got := s.Grid[0][1].Possibilities; len(got) != 1 || got[0] != 1 {
+		t.Errorf("middle cell = %v, want [1]", got)
+	}
+}
+
+func TestFixedSeedFinishes(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules Rules[int]
+		topo  Topology
+	}{
+		{"free", free(4), nil},
+		{"colors", colors(3), nil},
+		{"colors wrapped", colors(3), Square{Wrap: true}},
+		{"colors moore", colors(5), Moore{}},
+		{"colors hex", colors(4), Hex{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := New(16, 10, 42, tt.topo, tt.rules)
+			b := New(16, 10, 42, tt.topo, tt.rules)
+			if !solve(a, 100000) || !solve(b, 100000) {
+				t.Fatal("not finished")
+			}
+			if !valid(a) {
+				t.Fatal("finished grid breaks the rules")
+			}
+			for y := range a.Grid {
+				for x := range a.Grid[y] {
+					if a.Grid[y][x].Type != b.Grid[y][x].Type {
+						t.Fatalf("same seed, different tile at %d,%d", x, y)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestZeroWeight(t *testing.T) {
+	rules := free(3)
+	rules.Weight = func(t int) int {
+		if t == 2 {
+			return 0
+		}
+		return 1
+	}
+	s := New(10, 10, 1, nil, rules)
+	if !solve(s, 1000) {
+		t.Fatal("not finished")
+	}
+	for y := range s.Grid {
+		for x := range s.Grid[y] {
+			if s.Grid[y][x].Type == 2 {
+				t.Fatalf("zero-weight tile picked at %d,%d", x, y)
+			}
+		}
+	}
+
+	// A cell left with only the zero-weight tile is a contradiction, not a
+	// panic
+	rules.Seed = func(s *Solver[int]) {
+		s.Restrict(0, 0, func(t int) bool { return t == 2 })
+	}
+	s = New(4, 4, 1, nil, rules)
+	if solve(s, 100) {
+		t.Fatal("finished with a cell that can only hold a zero-weight tile")
+	}
+	if s.Restarts == 0 {
+		t.Error("no restarts")
+	}
+}
+
+func TestSeedHook(t *testing.T) {
+	rules := colors(3)
+	rules.Seed = func(s *Solver[int]) {
+		s.Pin(0, 0, 2)
+		s.Pin(5, 5, 1)
+	}
+	s := New(8, 8, 3, nil, rules)
+	if !solve(s, 100000) {
+		t.Fatal("not finished")
+	}
+	if s.Grid[0][0].Type != 2 || s.Grid[5][5].Type != 1 {
+		t.Errorf("pins lost: %d at 0,0 and %d at 5,5", s.Grid[0][0].Type, s.Grid[5][5].Type)
+	}
+}
@@ -0,0 +1,162 @@
+package solver
+
+// Event is one cell change made by the solver.
+type Event[T ~int] struct {
+	X, Y          int
+	Before, After Tile[T]
+}
+
+// Frame is everything one Step changed: the observed cell, the
+// propagation that followed and any backtracking or restart it caused.
+// Changes made outside Step, such as pins, get a frame of their own with
+// no observed cell.
+type Frame[T ~int] struct {
+	X, Y        int // Observed cell, -1 if none
+	Tile        T   // Tile chosen for it
+	Backtracked bool
+	Restarted   bool
+	Events      []Event[T]
+}
+
+// Timeline records a solver's frames and keeps a view of the grid at any
+// point in them, for stepping through a generation after the fact.
+// Rewinding the view does not rewind the solver: moving forward replays
+// recorded frames first and only steps the solver once the view is live.
+type Timeline[T ~int] struct {
+	s      *Solver[T]
+	frames []Frame[T]
+	open   bool // The last frame is still being recorded
+	grid   [][]Tile[T]
+	pos    int // Frames applied to grid
+}
+
+// Record starts recording s from its current state. Calling it again
+// returns the same timeline.
+func (s *Solver[T]) Record() *Timeline[T] {
+	if s.timeline != nil {
+		return s.timeline
+	}
+	tl := &Timeline[T]{s: s, grid: make([][]Tile[T], s.Height)}
+	for y := range tl.grid {
+		tl.grid[y] = append([]Tile[T](nil), s.Grid[y]...)
+	}
+	s.timeline = tl
+	return tl
+}
+
+// begin opens the frame for one Step.
+func (tl *Timeline[T]) begin() {
+	tl.frames = append(tl.frames, Frame[T]{X: -1, Y: -1})
+	tl.open = true
+	if tl.pos == len(tl.frames)-1 {
+		tl.pos++
+	}
+}
+
+// end closes the frame opened by begin, dropping it if nothing happened.
+func (tl *Timeline[T]) end() {
+	last := len(tl.frames) - 1
+	if f := tl.frames[last]; f.X < 0 && len(f.Events) == 0 {
+		tl.frames = tl.frames[:last]
+		if tl.pos > last {
+			tl.pos = last
+		}
+	}
+	tl.open = false
+}
+
+// frame is the frame being recorded, opening one if needed.
+func (tl *Timeline[T]) frame() *Frame[T] {
+	if !tl.open {
+		tl.begin()
+	}
+	return &tl.frames[len(tl.frames)-1]
+}
+
+// record adds a change to the open frame, and to the view if it is live.
+func (tl *Timeline[T]) record(x, y int, before, after Tile[T]) {
+	f := tl.frame()
+	f.Events = append(f.Events, Event[T]{X: x, Y: y, Before: before, After: after})
+	if tl.pos == len(tl.frames) {
+		tl.grid[y][x] = after
+	}
+}
+
+// observe notes the cell the open frame collapsed.
+func (tl *Timeline[T]) observe(x, y int, t T) {
+	f := tl.frame()
+	f.X, f.Y, f.Tile = x, y, t
+}
+
+// Len is the number of recorded frames.
+func (tl *Timeline[T]) Len() int {
+	return len(tl.frames)
+}
+
+// Pos is how many frames the view shows; Len when it is live.
+func (tl *Timeline[T]) Pos() int {
+	return tl.pos
+}
+
+// Live reports whether the view is at the end of the recording.
+func (tl *Timeline[T]) Live() bool {
+	return tl.pos == len(tl.frames)
+}
+
+// Grid is the grid as of Pos. It must not be modified.
+func (tl *Timeline[T]) Grid() [][]Tile[T] {
+	return tl.grid
+}
+
+// Seek moves the view to pos, clamped to the recording.
+func (tl *Timeline[T]) Seek(pos int) {
+	pos = max(0, min(pos, len(tl.frames)))
+	for tl.pos < pos {
+		for _, e := range tl.frames[tl.pos].Events {
+			tl.grid[e.Y][e.X] = e.After
+		}
+		tl.pos++
+	}
+	for tl.pos > pos {
+		tl.pos--
+		events := tl.frames[tl.pos].Events
+		for i := len(events) - 1; i >= 0; i-- {
+			e := events[i]
+			tl.grid[e.Y][e.X] = e.Before
+		}
+	}
+}
+
+// Forward moves the view one frame on, stepping the solver when the view
+// is live. It returns true once the view is live and the grid is done.
+func (tl *Timeline[T]) Forward() bool {
+	if !tl.Live() {
+		tl.Seek(tl.pos + 1)
+		return false
+	}
+	return tl.s.Step()
+}
+
+// Marks summarizes the last frame the view shows, for highlighting.
+type Marks struct {
+	X, Y        int             // Observed cell, -1 if none
+	Tile        int             // Tile chosen for it
+	Shrunk      map[[2]int]bool // Other cells that lost possibilities
+	Backtracked bool
+	Restarted   bool
+}
+
+// Marks describes the frame just before Pos; X is -1 at the start.
+func (tl *Timeline[T]) Marks() Marks {
+	if tl.pos == 0 {
+		return Marks{X: -1, Y: -1}
+	}
+	f := tl.frames[tl.pos-1]
+	m := Marks{X: f.X, Y: f.Y, Tile: int(f.Tile), Shrunk: map[[2]int]bool{}, Backtracked: f.Backtracked, Restarted: f.Restarted}
+	for _, e := range f.Events {
+		if (e.X != f.X || e.Y != f.Y) && len(e.After.Possibilities) < len(e.Before.Possibilities) {
+			m.Shrunk[[2]int{e.X, e.Y}] = true
+		}
+	}
+	return m
+}
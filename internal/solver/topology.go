@@ -0,0 +1,145 @@
+package solver
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Topology decides which cells are neighbors. Directions are numbered
+// 0..Dirs()-1 and every direction has an opposite, so rules can be written
+// as "b may sit in direction d of a".
+type Topology interface {
+	Dirs() int
+	Opposite(dir int) int
+	// Step moves one cell from (x, y) in direction dir. ok is false when
+	// the move leaves a width x height grid.
+	Step(x, y, dir, width, height int) (nx, ny int, ok bool)
+}
+
+// Square directions, in the order used for socket tables. Moore adds the
+// diagonals after them.
+const (
+	Up = iota
+	Right
+	Down
+	Left
+	UpRight
+	DownRight
+	DownLeft
+	UpLeft
+)
+
+// Hex directions, clockwise from north-east.
+const (
+	HexNE = iota
+	HexE
+	HexSE
+	HexSW
+	HexW
+	HexNW
+)
+
+// Square is the plain four-neighbor grid. With Wrap set, opposite edges
+// meet, so the map tiles seamlessly.
+type Square struct {
+	Wrap bool
+}
+
+var squareOffsets = [8][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}, {1, -1}, {1, 1}, {-1, 1}, {-1, -1}}
+
+func (Square) Dirs() int            { return 4 }
+func (Square) Opposite(dir int) int { return (dir + 2) % 4 }
+
+func (t Square) Step(x, y, dir, width, height int) (int, int, bool) {
+	return wrap(x+squareOffsets[dir][0], y+squareOffsets[dir][1], width, height, t.Wrap)
+}
+
+// Moore is the square grid with diagonal neighbors too, so rules also hold
+// corner to corner.
+type Moore struct {
+	Wrap bool
+}
+
+func (Moore) Dirs() int { return 8 }
+
+func (Moore) Opposite(dir int) int {
+	if dir < 4 {
+		return (dir + 2) % 4
+	}
+	return 4 + (dir-2)%4
+}
+
+func (t Moore) Step(x, y, dir, width, height int) (int, int, bool) {
+	return wrap(x+squareOffsets[dir][0], y+squareOffsets[dir][1], width, height, t.Wrap)
+}
+
+// Hex is a grid of pointy-topped hexagons stored as offset rows: odd rows
+// sit half a cell to the right of even ones. A wrapping hex grid needs an
+// even height, or the rows on either side of the seam do not line up.
+type Hex struct {
+	Wrap bool
+}
+
+// hexOffsets[row parity][dir]
+var hexOffsets = [2][6][2]int{
+	{{0, -1}, {1, 0}, {0, 1}, {-1, 1}, {-1, 0}, {-1, -1}},
+	{{1, -1}, {1, 0}, {1, 1}, {0, 1}, {-1, 0}, {0, -1}},
+}
+
+func (Hex) Dirs() int            { return 6 }
+func (Hex) Opposite(dir int) int { return (dir + 3) % 6 }
+
+func (t Hex) Step(x, y, dir, width, height int) (int, int, bool) {
+	off := hexOffsets[y&1][dir]
+	return wrap(x+off[0], y+off[1], width, height, t.Wrap)
+}
+
+// wrap folds (x, y) back onto a width x height grid when around is set,
+// and otherwise reports whether it is on the grid.
+func wrap(x, y, width, height int, around bool) (int, int, bool) {
+	if around {
+		return (x%width + width) % width, (y%height + height) % height, true
+	}
+	if x < 0 || x >= width || y < 0 || y >= height {
+		return 0, 0, false
+	}
+	return x, y, true
+}
+
+// topologies are the names accepted by NewTopology.
+var topologies = []string{"square", "moore", "hex"}
+
+// Topologies lists the topology names for flags and menus.
+func Topologies() []string {
+	return append([]string(nil), topologies...)
+}
+
+// NewTopology looks a topology up by name, optionally wrapping its edges.
+func NewTopology(name string, wrapped bool) (Topology, error) {
+	switch name {
+	case "square":
+		return Square{Wrap: wrapped}, nil
+	case "moore":
+		return Moore{Wrap: wrapped}, nil
+	case "hex":
+		return Hex{Wrap: wrapped}, nil
+	}
+	return nil, fmt.Errorf("unknown topology %q (want one of %s)", name, strings.Join(topologies, ", "))
+}
+
+// Describe names topo for status lines, e.g. "hex" or "square, wrapped".
+func Describe(topo Topology) string {
+	name, wrapped := "custom", false
+	switch t := topo.(type) {
+	case Square:
+		name, wrapped = "square", t.Wrap
+	case Moore:
+		name, wrapped = "moore", t.Wrap
+	case Hex:
+		name, wrapped = "hex", t.Wrap
+	}
+	if wrapped {
+		name += ", wrapped"
+	}
+	return name
+}
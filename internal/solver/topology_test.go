@@ -0,0 +1,119 @@
+package solver
+
+import "testing"
+
+var allTopologies = []struct {
+	name string
+	topo Topology
+}{
+	{"square", Square{}},
+	{"square wrapped", Square{Wrap: true}},
+	{"moore", Moore{}},
+	{"moore wrapped", Moore{Wrap: true}},
+	{"hex", Hex{}},
+	{"hex wrapped", Hex{Wrap: true}},
+}
+
+// TestStepOpposite checks that stepping back the opposite way returns to
+// the start from every cell in every direction, and that a cell's
+// neighbors are all different.
+func TestStepOpposite(t *testing.T) {
+	const w, h = 7, 6 // Even height, so wrapped hex rows line up
+	for _, tt := range allTopologies {
+		t.Run(tt.name, func(t *testing.T) {
+			topo := tt.topo
+			for d := 0; d < topo.Dirs(); d++ {
+				if o := topo.Opposite(d); topo.Opposite(o) != d || o == d {
+					t.Errorf("Opposite(%d) = %d, whose opposite is %d", d, o, topo.Opposite(o))
+				}
+			}
+			for y := 0; y < h; y++ {
+				for x := 0; x < w; x++ {
+					seen := map[[2]int]bool{}
+					for d := 0; d < topo.Dirs(); d++ {
+						nx, ny, ok := topo.Step(x, y, d, w, h)
+						if !ok {
+							continue
+						}
+						if nx < 0 || nx >= w || ny < 0 || ny >= h {
+							t.Fatalf("%d,%d dir %d stepped off the grid to %d,%d", x, y, d, nx, ny)
+						}
+						if seen[[2]int{nx, ny}] {
+							t.Errorf("%d,%d dir %d: %d,%d is already a neighbor", x, y, d, nx, ny)
+						}
+						seen[[2]int{nx, ny}] = true
+						bx, by, ok := topo.Step(nx, ny, topo.Opposite(d), w, h)
+						if !ok || bx != x || by != y {
+							t.Errorf("%d,%d dir %d to %d,%d, back to %d,%d (%v)", x, y, d, nx, ny, bx, by, ok)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStep(t *testing.T) {
+	const w, h = 5, 4
+	tests := []struct {
+		name   string
+		topo   Topology
+		x, y   int
+		dir    int
+		nx, ny int
+		ok     bool
+	}{
+		{"square right", Square{}, 1, 1, Right, 2, 1, true},
+		{"square off the top", Square{}, 2, 0, Up, 0, 0, false},
+		{"square off the left", Square{}, 0, 2, Left, 0, 0, false},
+		{"square wraps up", Square{Wrap: true}, 2, 0, Up, 2, h - 1, true},
+		{"square wraps left", Square{Wrap: true}, 0, 2, Left, w - 1, 2, true},
+		{"square wraps right", Square{Wrap: true}, w - 1, 2, Right, 0, 2, true},
+		{"moore up-right", Moore{}, 1, 1, UpRight, 2, 0, true},
+		{"moore down-left", Moore{}, 1, 1, DownLeft, 0, 2, true},
+		{"moore corner wraps", Moore{Wrap: true}, 0, 0, UpLeft, w - 1, h - 1, true},
+		// Odd rows sit half a cell right, so their upper neighbors are x and x+1
+		{"hex even row NE", Hex{}, 2, 2, HexNE, 2, 1, true},
+		{"hex even row NW", Hex{}, 2, 2, HexNW, 1, 1, true},
+		{"hex even row SE", Hex{}, 2, 2, HexSE, 2, 3, true},
+		{"hex even row SW", Hex{}, 2, 2, HexSW, 1, 3, true},
+		{"hex odd row NE", Hex{}, 2, 1, HexNE, 3, 0, true},
+		{"hex odd row NW", Hex{}, 2, 1, HexNW, 2, 0, true},
+		{"hex odd row SE", Hex{}, 2, 1, HexSE, 3, 2, true},
+		{"hex odd row SW", Hex{}, 2, 1, HexSW, 2, 2, true},
+		{"hex east", Hex{}, 2, 1, HexE, 3, 1, true},
+		{"hex west", Hex{}, 2, 2, HexW, 1, 2, true},
+		{"hex off the edge", Hex{}, 0, 2, HexSW, 0, 0, false},
+		{"hex wraps", Hex{Wrap: true}, 0, 2, HexSW, w - 1, 3, true},
+		{"hex odd row wraps", Hex{Wrap: true}, w - 1, 3, HexSE, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			nx, ny, ok := tt.topo.Step(tt.x, tt.y, tt.dir, w, h)
+			if ok != tt.ok || (ok && (nx != tt.nx || ny != tt.ny)) {
+				t.Errorf("Step(%d, %d, %d) = %d, %d, %v, want %d, %d, %v", tt.x, tt.y, tt.dir, nx, ny, ok, tt.nx, tt.ny, tt.ok)
+			}
+		})
+	}
+}
+
+func TestNewTopology(t *testing.T) {
+	for _, name := range Topologies() {
+		for _, wrapped := range []bool{false, true} {
+			topo, err := NewTopology(name, wrapped)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := name
+			if wrapped {
+				want += ", wrapped"
+			}
+			if got := Describe(topo); got != want {
+				t.Errorf("Describe(NewTopology(%q, %v)) = %q", name, wrapped, got)
+			}
+		}
+	}
+	if _, err := NewTopology("triangle", false); err == nil {
+		t.Error("unknown topology accepted")
+	}
+}
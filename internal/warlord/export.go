@@ -0,0 +1,42 @@
+package warlord
+
+import "atlas.games/internal/mapio"
+
+// tileInfo mirrors the map view's glyphs and colors for the exporters.
+var tileInfo = []mapio.Cell{
+	Water:      {Name: "water", Glyph: "~", Color: "33"},
+	Land:       {Name: "land", Glyph: ".", Color: "240"},
+	Forest:     {Name: "forest", Glyph: "↑", Color: "34"},
+	Mountain:   {Name: "mountain", Glyph: "▲", Color: "250"},
+	Road:       {Name: "road", Glyph: ".", Color: "240"},
+	Stronghold: {Name: "stronghold", Glyph: "H", Color: "46"},
+	Cache:      {Name: "cache", Glyph: "$", Color: "226"},
+	Market:     {Name: "market", Glyph: "B", Color: "226"},
+	Princess:   {Name: "princess", Glyph: "P", Color: "46"},
+	WallV:      {Name: "wall_v", Glyph: "|", Color: "244"},
+	WallH:      {Name: "wall_h", Glyph: "-", Color: "244"},
+	WallTL:     {Name: "wall_tl", Glyph: "+", Color: "244"},
+	WallTR:     {Name: "wall_tr", Glyph: "+", Color: "244"},
+	WallBL:     {Name: "wall_bl", Glyph: "+", Color: "244"},
+	WallBR:     {Name: "wall_br", Glyph: "+", Color: "244"},
+}
+
+// Map snapshots the terrain for the writers in mapio. Units are not part
+// of the map.
+func (g *GameState) Map() *mapio.Map {
+	m := &mapio.Map{
+		Kind:   "warlord",
+		Width:  g.Width,
+		Height: g.Height,
+		Seed:   g.Seed,
+		Cells:  make([][]mapio.Cell, g.Height),
+		Legend: tileInfo,
+	}
+	for y := 0; y < g.Height; y++ {
+		m.Cells[y] = make([]mapio.Cell, g.Width)
+		for x := 0; x < g.Width; x++ {
+			m.Cells[y][x] = tileInfo[g.Grid[y][x].Type]
+		}
+	}
+	return m
+}
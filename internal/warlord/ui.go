@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"math"
 	"strings"
-	"math/rand"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"atlas.games/internal/registry"
 )
 
 const (
@@ -61,10 +61,21 @@ type Model struct {
 	duelSummary  string
 }
 
-func NewModel() Model {
+func init() {
+	registry.Register(registry.Game{
+		ID:          "warlord",
+		Title:       "Atlas Warlord",
+		Description: "Turn-based tactics. Level your commander and slay the dragon.",
+		Category:    registry.Strategy,
+		Order:       1,
+		New:         func(seed int64) tea.Model { return NewModel(seed) },
+	})
+}
+
+func NewModel(seed int64) Model {
 	w, h := 120, 40
 	return Model{
-		game:         NewGame(w, h),
+		game:         NewGame(w, h, seed),
 		state:        StateMap,
 		selectedUnit: -1,
 		cursorX:      w / 2,
@@ -212,8 +223,8 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 }
 
 func (m *Model) resolveDuel() {
-	m.playerRoll = rand.Intn(6) + 1
-	m.enemyRoll = rand.Intn(6) + 1
+	m.playerRoll = m.game.rng.Intn(6) + 1
+	m.enemyRoll = m.game.rng.Intn(6) + 1
 	pwr := m.duelAttacker.Attack + m.playerRoll
 	enemyDefRoll := m.duelTarget.Defense + m.enemyRoll
 	dmg := pwr - enemyDefRoll; if dmg < 1 { dmg = 1 }
@@ -236,7 +247,7 @@ func (m *Model) resolveDuel() {
 		}
 		m.game.CheckLevelUp(m.duelAttacker)
 	} else {
-		cntRoll := rand.Intn(6) + 1
+		cntRoll := m.game.rng.Intn(6) + 1
 		cntDmg := (m.duelTarget.Attack + cntRoll) / 2
 		if cntDmg < 1 { cntDmg = 1 }
 		m.duelAttacker.Health -= cntDmg
@@ -313,6 +324,7 @@ func (m Model) View() string {
 	var sb strings.Builder
 	sb.WriteString(lipgloss.NewStyle().Bold(true).Render("MISSION STATUS") + "\n")
 	sb.WriteString(fmt.Sprintf("DAY: %d | XP: %d\n", m.game.Turn, m.game.Score))
+	sb.WriteString(fmt.Sprintf("SEED: %d\n", m.game.Seed))
 	sb.WriteString(fmt.Sprintf("LOC: [%d, %d]\n\n", m.cursorX-m.game.BaseX, m.game.BaseY-m.cursorY))
 	
 	player := m.game.Units[0]
@@ -4,7 +4,6 @@ import (
 	"fmt"
 	"math"
 	"math/rand"
-	"time"
 )
 
 type TileType int
@@ -77,14 +76,17 @@ type GameState struct {
 	GameWon       bool
 	BaseX, BaseY  int
 	Logs          []string
+	Seed          int64
+	rng           *rand.Rand
 }
 
-func NewGame(w, h int) *GameState {
-	rand.Seed(time.Now().UnixNano())
+func NewGame(w, h int, seed int64) *GameState {
 	g := &GameState{
 		Width:  w,
 		Height: h,
 		Grid:   make([][]Tile, h),
+		Seed:   seed,
+		rng:    rand.New(rand.NewSource(seed)),
 		Turn:   1,
 		Logs:   []string{"🛰️ System Boot: Mission Red Dragon active."},
 	}
@@ -92,7 +94,7 @@ func NewGame(w, h int) *GameState {
 	for y := 0; y < h; y++ {
 		g.Grid[y] = make([]Tile, w)
 		for x := 0; x < w; x++ {
-			r := rand.Float64()
+			r := g.rng.Float64()
 			if r < 0.07 { g.Grid[y][x].Type = Water
 			} else if r < 0.15 { g.Grid[y][x].Type = Forest
 			} else if r < 0.20 { g.Grid[y][x].Type = Mountain
@@ -117,7 +119,7 @@ func NewGame(w, h int) *GameState {
 	// Place Cities with Markets
 	numCities := 4
 	for i := 0; i < numCities; i++ {
-		cx, cy := rand.Intn(w-10)+5, rand.Intn(h-10)+5
+		cx, cy := g.rng.Intn(w-10)+5, g.rng.Intn(h-10)+5
 		if math.Abs(float64(cx-g.BaseX)) < 15 && math.Abs(float64(cy-g.BaseY)) < 10 {
 			i--
 			continue
@@ -126,9 +128,9 @@ func NewGame(w, h int) *GameState {
 	}
 
 	// Place Princess
-	px, py := 10 + rand.Intn(10), 10 + rand.Intn(10)
-	if rand.Float64() < 0.5 { px = w - 15 - rand.Intn(10) }
-	if rand.Float64() < 0.5 { py = h - 15 - rand.Intn(10) }
+	px, py := 10 + g.rng.Intn(10), 10 + g.rng.Intn(10)
+	if g.rng.Float64() < 0.5 { px = w - 15 - g.rng.Intn(10) }
+	if g.rng.Float64() < 0.5 { py = h - 15 - g.rng.Intn(10) }
 	g.Grid[py][px].Type = Princess
 
 	// Dragon Guard
@@ -145,12 +147,12 @@ func NewGame(w, h int) *GameState {
 
 	// Spawn Minions
 	for i := 0; i < 45; i++ {
-		ex, ey := rand.Intn(w), rand.Intn(h)
+		ex, ey := g.rng.Intn(w), g.rng.Intn(h)
 		if math.Abs(float64(ex-g.BaseX)) > 10 && g.Grid[ey][ex].Type == Land {
 			uType := Scout
 			hp, atk, def := 15, 8, 4
 			maxM := 4
-			if rand.Float64() < 0.4 { uType = Knight; hp, atk, def = 30, 12, 6; maxM = 2 }
+			if g.rng.Float64() < 0.4 { uType = Knight; hp, atk, def = 30, 12, 6; maxM = 2 }
 			g.Units = append(g.Units, &Unit{
 				ID: len(g.Units), Type: uType, Team: TeamInvader, X: ex, Y: ey,
 				Health: hp, MaxHP: hp, Level: 1, Attack: atk, Defense: def, Moves: maxM, MaxMoves: maxM,
@@ -230,7 +232,7 @@ func (g *GameState) aiAction(u *Unit) {
 
 	if dist < huntRange {
 		if dist <= 1 {
-			roll := rand.Intn(6) + 1
+			roll := g.rng.Intn(6) + 1
 			dmg := (u.Attack + roll) - commander.Defense
 			if dmg < 1 { dmg = 1 }
 			commander.Health -= dmg
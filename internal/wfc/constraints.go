@@ -0,0 +1,156 @@
+package wfc
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/fezcode/go-piml"
+)
+
+// Pin fixes one cell to a tile before the solver runs.
+type Pin struct {
+	X, Y int
+	Tile TileType
+}
+
+// Constraints are the pins painted onto a width x height map. At most one
+// pin is kept per cell.
+type Constraints struct {
+	Width   int
+	Height  int
+	Tileset *Tileset
+	pins    map[[2]int]TileType
+}
+
+// NewConstraints starts an empty set of pins for a width x height map.
+func NewConstraints(width, height int, ts *Tileset) *Constraints {
+	return &Constraints{Width: width, Height: height, Tileset: ts, pins: map[[2]int]TileType{}}
+}
+
+// Set pins (x, y) to t. Positions off the map are ignored.
+func (c *Constraints) Set(x, y int, t TileType) {
+	if x < 0 || x >= c.Width || y < 0 || y >= c.Height {
+		return
+	}
+	c.pins[[2]int{x, y}] = t
+}
+
+// Clear removes the pin at (x, y), if any.
+func (c *Constraints) Clear(x, y int) {
+	delete(c.pins, [2]int{x, y})
+}
+
+// At returns the pin at (x, y); ok is false for a free cell.
+func (c *Constraints) At(x, y int) (t TileType, ok bool) {
+	t, ok = c.pins[[2]int{x, y}]
+	return t, ok
+}
+
+// Len is the number of pinned cells.
+func (c *Constraints) Len() int {
+	return len(c.pins)
+}
+
+// Pins lists the pins in row order.
+func (c *Constraints) Pins() []Pin {
+	pins := make([]Pin, 0, len(c.pins))
+	for p, t := range c.pins {
+		pins = append(pins, Pin{X: p[0], Y: p[1], Tile: t})
+	}
+	sort.Slice(pins, func(i, j int) bool {
+		if pins[i].Y != pins[j].Y {
+			return pins[i].Y < pins[j].Y
+		}
+		return pins[i].X < pins[j].X
+	})
+	return pins
+}
+
+// Write saves the pins in PIML:
+//
+//	(tileset) temperate
+//	(width) 200
+//	(height) 60
+//	(pins)
+//	  > 10,4 water
+func (c *Constraints) Write(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "# Land Creator pins: x,y tile\n")
+	fmt.Fprintf(bw, "(tileset) %s\n(width) %d\n(height) %d\n(pins)\n", c.Tileset.Name, c.Width, c.Height)
+	for _, p := range c.Pins() {
+		fmt.Fprintf(bw, "  > %d,%d %s\n", p.X, p.Y, c.Tileset.Def(p.Tile).ID)
+	}
+	return bw.Flush()
+}
+
+// Save writes the pins to the named file.
+func (c *Constraints) Save(name string) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := c.Write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// constraintsFile is the PIML layout of saved pins, see Write.
+type constraintsFile struct {
+	Tileset string   `piml:"tileset"`
+	Width   int      `piml:"width"`
+	Height  int      `piml:"height"`
+	Pins    []string `piml:"pins"`
+}
+
+// ParseConstraints reads pins saved by Write. The pins must have been
+// painted with ts, since tiles are stored by ID.
+func ParseConstraints(r io.Reader, ts *Tileset) (*Constraints, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var file constraintsFile
+	if err := piml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	if file.Tileset != "" && file.Tileset != ts.Name {
+		return nil, fmt.Errorf("pins were painted for tileset %s, not %s", file.Tileset, ts.Name)
+	}
+	if file.Width <= 0 || file.Height <= 0 {
+		return nil, fmt.Errorf("pins need a positive (width) and (height)")
+	}
+
+	c := NewConstraints(file.Width, file.Height, ts)
+	for i, pin := range file.Pins {
+		pos, id, _ := strings.Cut(pin, " ")
+		xs, ys, ok := strings.Cut(pos, ",")
+		x, errX := strconv.Atoi(xs)
+		y, errY := strconv.Atoi(ys)
+		if !ok || errX != nil || errY != nil {
+			return nil, fmt.Errorf("pin %d: want \"x,y tile\", got %q", i+1, pin)
+		}
+		t, ok := ts.Lookup(strings.TrimSpace(id))
+		if !ok {
+			return nil, fmt.Errorf("pin %d: unknown tile %q in tileset %s", i+1, id, ts.Name)
+		}
+		c.Set(x, y, t)
+	}
+	return c, nil
+}
+
+// LoadConstraints reads pins from the named file.
+func LoadConstraints(name string, ts *Tileset) (*Constraints, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseConstraints(f, ts)
+}
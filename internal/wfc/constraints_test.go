@@ -0,0 +1,58 @@
+package wfc
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestConstraintsRoundTrip(t *testing.T) {
+	ts := DefaultTileset()
+	water, _ := ts.Lookup("water")
+	lava, _ := ts.Lookup("lava")
+	c := NewConstraints(30, 20, ts)
+	c.Set(10, 4, water)
+	c.Set(0, 0, lava)
+	c.Set(29, 19, water)
+	c.Set(40, 4, lava) // Off the map, dropped
+
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(buf.String(), "#") {
+		t.Fatalf("Write should start with a comment line, got %q", buf.String())
+	}
+	got, err := ParseConstraints(&buf, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Width != c.Width || got.Height != c.Height {
+		t.Errorf("size = %dx%d, want %dx%d", got.Width, got.Height, c.Width, c.Height)
+	}
+	if !slices.Equal(got.Pins(), c.Pins()) {
+		t.Errorf("pins = %v, want %v", got.Pins(), c.Pins())
+	}
+}
+
+func TestParseConstraintsErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		err  string
+	}{
+		{"other tileset", "(tileset) desert\n(width) 5\n(height) 5\n", "painted for tileset desert"},
+		{"no size", "(tileset) temperate\n", "positive (width) and (height)"},
+		{"bad position", "(width) 5\n(height) 5\n(pins)\n  > 1;2 water\n", "pin 1"},
+		{"unknown tile", "(width) 5\n(height) 5\n(pins)\n  > 1,2 water\n  > 2,2 slime\n", "pin 2: unknown tile"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseConstraints(strings.NewReader(tt.doc), DefaultTileset())
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want one containing %q", err, tt.err)
+			}
+		})
+	}
+}
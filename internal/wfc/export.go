@@ -0,0 +1,9 @@
+package wfc
+
+import "atlas.games/internal/mapio"
+
+// Map snapshots the grid for the writers in mapio. Cells that have not
+// collapsed yet are reported as mapio.Unresolved.
+func (w *WFC) Map() *mapio.Map {
+	return w.Export("land", w.Tileset.Cell)
+}
@@ -0,0 +1,142 @@
+package wfc
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"atlas.games/internal/solver"
+)
+
+// Seeding chooses how plantBiomes scatters the primordial seeds.
+type Seeding int
+
+const (
+	// UniformSeeding plants each tile's (seeds) count at uniformly random
+	// cells.
+	UniformSeeding Seeding = iota
+	// NoiseSeeding samples height and moisture noise and narrows each
+	// cell to the tiles whose climate fits there, so continents, ranges
+	// and coasts have large-scale shape.
+	NoiseSeeding
+)
+
+// Seedings lists the seeding modes by their CLI names.
+var Seedings = []string{"uniform", "noise"}
+
+func (s Seeding) String() string {
+	return Seedings[s]
+}
+
+// ParseSeeding looks up a seeding mode by its CLI name.
+func ParseSeeding(name string) (Seeding, error) {
+	for i, n := range Seedings {
+		if n == name {
+			return Seeding(i), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown seeding %q (want one of %s)", name, strings.Join(Seedings, ", "))
+}
+
+const (
+	// climateMargin is how many percentiles a tile may stray outside its
+	// climate, so neighboring climates overlap and blend.
+	climateMargin = 8
+	// climateTries is how many random cells a seed tries before giving
+	// up on finding its climate.
+	climateTries = 50
+	// climateScale is the size in cells of the largest noise features.
+	climateScale   = 48.0
+	climateOctaves = 4
+)
+
+// noise is fractal value noise: random values on an integer lattice,
+// smoothly interpolated and summed over octaves of halving size. It
+// depends only on its seed, so any coordinate can be sampled in any order.
+type noise struct {
+	seed uint64
+}
+
+// at samples the noise at (x, y) in cells, in [0, 1).
+func (n noise) at(x, y float64) float64 {
+	sum, total, amp, freq := 0.0, 0.0, 1.0, 1/climateScale
+	for o := 0; o < climateOctaves; o++ {
+		sum += amp * n.octave(uint64(o), x*freq, y*freq)
+		total += amp
+		amp /= 2
+		freq *= 2
+	}
+	return sum / total
+}
+
+// octave is one layer of smoothly interpolated lattice values.
+func (n noise) octave(o uint64, x, y float64) float64 {
+	x0, y0 := math.Floor(x), math.Floor(y)
+	fx, fy := smooth(x-x0), smooth(y-y0)
+	ix, iy := int64(x0), int64(y0)
+	top := lerp(n.lattice(o, ix, iy), n.lattice(o, ix+1, iy), fx)
+	bottom := lerp(n.lattice(o, ix, iy+1), n.lattice(o, ix+1, iy+1), fx)
+	return lerp(top, bottom, fy)
+}
+
+// lattice is the random value at a lattice point, from a splitmix64 hash.
+func (n noise) lattice(o uint64, x, y int64) float64 {
+	h := n.seed ^ o*0x9e3779b97f4a7c15 ^ uint64(x)*0xbf58476d1ce4e5b9 ^ uint64(y)*0x94d049bb133111eb
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return float64(h>>11) / (1 << 53)
+}
+
+func smooth(t float64) float64 { return t * t * (3 - 2*t) }
+
+func lerp(a, b, t float64) float64 { return a + (b-a)*t }
+
+// percentiles ranks values from 0 to 100, so climates cover a share of
+// the map whatever the spread of the noise.
+func percentiles(values []float64) []int {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return values[order[a]] < values[order[b]] })
+	ranks := make([]int, len(values))
+	for rank, i := range order {
+		ranks[i] = rank * 100 / max(1, len(values)-1)
+	}
+	return ranks
+}
+
+// restrictClimate narrows every cell to the tiles whose climate holds the
+// height and moisture there, give or take climateMargin, plus the tiles
+// with no climate. The solver still picks among them, so zones blend
+// instead of meeting in hard contours. It reports false if the tileset has
+// no climates, leaving the grid untouched.
+func (w *WFC) restrictClimate(s *solver.Solver[TileType]) bool {
+	free := map[TileType]bool{}
+	any := false
+	for _, t := range w.Tileset.Types() {
+		free[t] = w.Tileset.Def(t).Climate == nil
+		any = any || !free[t]
+	}
+	if !any {
+		return false
+	}
+	height, moisture := noise{seed: uint64(s.Seed)}, noise{seed: ^uint64(s.Seed)}
+	hs, ms := make([]float64, s.Width*s.Height), make([]float64, s.Width*s.Height)
+	for i := range hs {
+		x, y := float64(i%s.Width), float64(i/s.Width)
+		hs[i], ms[i] = height.at(x, y), moisture.at(x, y)
+	}
+	hp, mp := percentiles(hs), percentiles(ms)
+	for i := range hp {
+		s.Restrict(i%s.Width, i/s.Width, func(t TileType) bool {
+			c := w.Tileset.Def(t).Climate
+			return free[t] || c.near(hp[i], mp[i], climateMargin)
+		})
+	}
+	return true
+}
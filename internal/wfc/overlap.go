@@ -0,0 +1,128 @@
+package wfc
+
+import (
+	"fmt"
+
+	"atlas.games/internal/mapio"
+	"atlas.games/internal/solver"
+)
+
+// DefaultPatternSize is the N of the NxN patterns learned from a sample.
+const DefaultPatternSize = 3
+
+// Pattern indexes the patterns an Overlap learned from its sample.
+type Pattern int
+
+// dirOffsets matches the solver's square directions.
+var dirOffsets = [4][2]int{
+	solver.Up:    {0, -1},
+	solver.Right: {1, 0},
+	solver.Down:  {0, 1},
+	solver.Left:  {-1, 0},
+}
+
+// Overlap is the overlapping-model land generator. Instead of hand-written
+// neighbor rules it cuts every NxN window out of a sample, counts how often
+// each one occurs, and lets two patterns touch wherever they agree on the
+// cells they share. The output therefore only contains local arrangements
+// that appear somewhere in the sample. Each output cell shows the top-left
+// tile of its pattern.
+type Overlap struct {
+	*solver.Solver[Pattern]
+	Tileset *Tileset
+	Sample  *Sample
+	N       int
+
+	patterns [][]TileType // N*N tiles per pattern, row by row
+}
+
+// NewOverlap learns n x n patterns from sample and prepares a width x
+// height map. The sample wraps around at its edges.
+func NewOverlap(width, height int, seed int64, ts *Tileset, sample *Sample, n int) (*Overlap, error) {
+	if n < 2 || n > sample.Width || n > sample.Height {
+		return nil, fmt.Errorf("pattern size %d does not fit sample %s (%dx%d)", n, sample.Name, sample.Width, sample.Height)
+	}
+	o := &Overlap{Tileset: ts, Sample: sample, N: n}
+	counts := o.learn()
+
+	tiles := make([]Pattern, len(o.patterns))
+	for i := range tiles {
+		tiles[i] = Pattern(i)
+	}
+	o.Solver = solver.New(width, height, seed, nil, solver.Rules[Pattern]{
+		Tiles:  tiles,
+		Weight: func(p Pattern) int { return counts[p] },
+		Allows: o.agrees,
+	})
+	return o, nil
+}
+
+// learn collects the distinct windows of the sample in scan order and
+// returns how often each occurs.
+func (o *Overlap) learn() []int {
+	var counts []int
+	index := map[string]int{}
+	for y := 0; y < o.Sample.Height; y++ {
+		for x := 0; x < o.Sample.Width; x++ {
+			p := make([]TileType, 0, o.N*o.N)
+			for dy := 0; dy < o.N; dy++ {
+				row := o.Sample.Tiles[(y+dy)%o.Sample.Height]
+				for dx := 0; dx < o.N; dx++ {
+					p = append(p, row[(x+dx)%o.Sample.Width])
+				}
+			}
+			key := fmt.Sprint(p)
+			if i, ok := index[key]; ok {
+				counts[i]++
+				continue
+			}
+			index[key] = len(o.patterns)
+			o.patterns = append(o.patterns, p)
+			counts = append(counts, 1)
+		}
+	}
+	return counts
+}
+
+// agrees reports whether b, placed one cell in direction dir of a, matches
+// a on every cell the two patterns overlap.
+func (o *Overlap) agrees(a, b Pattern, dir int) bool {
+	dx, dy := dirOffsets[dir][0], dirOffsets[dir][1]
+	pa, pb := o.patterns[a], o.patterns[b]
+	for y := 0; y < o.N; y++ {
+		for x := 0; x < o.N; x++ {
+			bx, by := x-dx, y-dy
+			if bx < 0 || bx >= o.N || by < 0 || by >= o.N {
+				continue
+			}
+			if pa[y*o.N+x] != pb[by*o.N+bx] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// Patterns is the number of distinct patterns in the sample.
+func (o *Overlap) Patterns() int {
+	return len(o.patterns)
+}
+
+// Tile is the tile a cell holding p displays.
+func (o *Overlap) Tile(p Pattern) TileType {
+	return o.patterns[p][0]
+}
+
+// Map snapshots the grid for the writers in mapio, using the tileset's
+// palette. Cells that have not collapsed yet are reported as
+// mapio.Unresolved.
+func (o *Overlap) Map() *mapio.Map {
+	m := o.Export("land", func(p Pattern) mapio.Cell { return o.Tileset.Cell(o.Tile(p)) })
+	// List the whole tileset, as the tiled model does, not just the tiles
+	// the sample happens to use
+	m.Legend = m.Legend[:0]
+	for _, t := range o.Tileset.Types() {
+		m.Legend = append(m.Legend, o.Tileset.Cell(t))
+	}
+	return m
+}
@@ -0,0 +1,187 @@
+package wfc
+
+import (
+	"fmt"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// PinsFile is where paint mode saves and loads its pins.
+const PinsFile = "land-pins.piml"
+
+var (
+	freeStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("237"))
+	paintCursorBg = lipgloss.Color("240")
+)
+
+// brush is a stamp shape. Discs are twice as wide as they are tall so they
+// look round in a terminal.
+type brush struct {
+	name   string
+	radius int
+	round  bool
+}
+
+var brushes = []brush{
+	{name: "dot", radius: 0},
+	{name: "square 3", radius: 1},
+	{name: "disc 3", radius: 3, round: true},
+	{name: "disc 6", radius: 6, round: true},
+}
+
+// cells calls fn for every offset the brush covers.
+func (b brush) cells(fn func(dx, dy int)) {
+	rx := b.radius
+	if b.round {
+		rx = 2 * b.radius
+	}
+	for dy := -b.radius; dy <= b.radius; dy++ {
+		for dx := -rx; dx <= rx; dx++ {
+			if b.round && dx*dx+4*dy*dy > 4*b.radius*b.radius {
+				continue
+			}
+			fn(dx, dy)
+		}
+	}
+}
+
+// painter is the state of paint mode.
+type painter struct {
+	pins    *Constraints
+	x, y    int
+	tile    TileType
+	brush   int
+	penDown bool
+}
+
+func newPainter(pins *Constraints) *painter {
+	return &painter{
+		pins: pins,
+		x:    pins.Width / 2,
+		y:    pins.Height / 2,
+		tile: pins.Tileset.Types()[0],
+	}
+}
+
+// stamp pins the brush footprint at the cursor, or clears it when erase is
+// set.
+func (p *painter) stamp(erase bool) {
+	brushes[p.brush].cells(func(dx, dy int) {
+		if erase {
+			p.pins.Clear(p.x+dx, p.y+dy)
+		} else {
+			p.pins.Set(p.x+dx, p.y+dy, p.tile)
+		}
+	})
+}
+
+// updatePaint handles a key in paint mode. It reports whether the user
+// asked to generate.
+func (m *Model) updatePaint(msg tea.KeyMsg) (generate bool) {
+	p := m.paint
+	switch key := msg.String(); key {
+	case "up", "down", "left", "right":
+		switch key {
+		case "up":
+			p.y = max(p.y-1, 0)
+		case "down":
+			p.y = min(p.y+1, m.height-1)
+		case "left":
+			p.x = max(p.x-1, 0)
+		case "right":
+			p.x = min(p.x+1, m.width-1)
+		}
+		if p.penDown {
+			p.stamp(false)
+		}
+	case " ":
+		p.stamp(false)
+	case "x":
+		p.stamp(true)
+	case "d":
+		p.penDown = !p.penDown
+		if p.penDown {
+			p.stamp(false)
+		}
+	case "b":
+		p.brush = (p.brush + 1) % len(brushes)
+	case "c":
+		m.pins = NewConstraints(m.width, m.height, m.wfc.Tileset)
+		p.pins = m.pins
+		m.notice = "Cleared all pins"
+	case "s":
+		if err := m.pins.Save(PinsFile); err != nil {
+			m.notice = "Save failed: " + err.Error()
+		} else {
+			m.notice = fmt.Sprintf("Saved %d pins to %s", m.pins.Len(), PinsFile)
+		}
+	case "l":
+		pins, err := LoadConstraints(PinsFile, m.wfc.Tileset)
+		if err != nil {
+			m.notice = "Load failed: " + err.Error()
+			break
+		}
+		m.pins = pins
+		p.pins = pins
+		m.notice = fmt.Sprintf("Loaded %d pins from %s", pins.Len(), PinsFile)
+	case "g", "enter":
+		return true
+	default:
+		// Number keys pick a tile from the tileset
+		if len(key) == 1 && key[0] >= '1' && key[0] <= '9' {
+			types := m.wfc.Tileset.Types()
+			if i := int(key[0] - '1'); i < len(types) {
+				p.tile = types[i]
+			}
+		}
+	}
+	return false
+}
+
+// viewPaint draws the pins over an empty map, with the cursor and its
+// brush footprint highlighted.
+func (m Model) viewPaint() string {
+	p := m.paint
+	ts := m.wfc.Tileset
+
+	under := map[[2]int]bool{}
+	brushes[p.brush].cells(func(dx, dy int) { under[[2]int{p.x + dx, p.y + dy}] = true })
+
+	var sb strings.Builder
+	sb.WriteString("  " + titleStyle.Render(" ATLAS LANDSCAPE ENGINE - PAINT PINS ") + "\n")
+	for y := 0; y < m.height; y++ {
+		sb.WriteString("  ")
+		for x := 0; x < m.width; x++ {
+			style, glyph := freeStyle, "·"
+			if t, ok := m.pins.At(x, y); ok {
+				style, glyph = m.styles[t], ts.Def(t).Glyph
+			}
+			if under[[2]int{x, y}] {
+				style = style.Background(paintCursorBg)
+			}
+			sb.WriteString(style.Render(glyph))
+		}
+		sb.WriteString("\n")
+	}
+
+	var palette []string
+	for i, t := range ts.Types() {
+		label := fmt.Sprintf("%d:%s", i+1, ts.Def(t).ID)
+		if t == p.tile {
+			label = "[" + label + "]"
+		}
+		palette = append(palette, m.styles[t].Render(label))
+	}
+	pen := "up"
+	if p.penDown {
+		pen = "down"
+	}
+	sb.WriteString(fmt.Sprintf("  Cursor: %d,%d | Brush: %s | Pen: %s | Pins: %d | %s\n", p.x, p.y, brushes[p.brush].name, pen, m.pins.Len(), strings.Join(palette, " ")))
+	sb.WriteString("  [Arrows] Move  [Space] Stamp  [D] Pen  [X] Erase  [B] Brush  [C] Clear  [S] Save  [L] Load  [G] Generate  [P] Cancel  [Q] Exit")
+	if m.notice != "" {
+		sb.WriteString("\n  " + m.notice)
+	}
+	return sb.String()
+}
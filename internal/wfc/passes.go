@@ -0,0 +1,574 @@
+package wfc
+
+import (
+	"fmt"
+	"math/rand"
+	"strings"
+
+	"atlas.games/internal/mapio"
+)
+
+// Terrain passes post-process a finished land map. They read tile roles
+// from the tileset (see Roles), so a tileset without roles passes through
+// unchanged.
+const (
+	PassSmooth  = "smooth"  // Erode one-tile patches into their surroundings
+	PassRivers  = "rivers"  // Run rivers downhill from mountains to water
+	PassBeaches = "beaches" // Line land that touches water with sand
+	PassLabels  = "labels"  // Name continents, islands, seas and lakes
+)
+
+// Passes lists the terrain passes in the order they run.
+func Passes() []string {
+	return []string{PassSmooth, PassRivers, PassBeaches, PassLabels}
+}
+
+// ParsePasses reads a comma-separated list of pass names. "all" selects
+// every pass; "" and "none" select none.
+func ParsePasses(s string) (map[string]bool, error) {
+	on := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "", "none":
+		case "all":
+			for _, p := range Passes() {
+				on[p] = true
+			}
+		default:
+			known := false
+			for _, p := range Passes() {
+				known = known || p == name
+			}
+			if !known {
+				return nil, fmt.Errorf("unknown pass %q (want all, none or a list of %s)", name, strings.Join(Passes(), ", "))
+			}
+			on[name] = true
+		}
+	}
+	return on, nil
+}
+
+// Feature is drawn over a terrain cell's tile.
+type Feature int
+
+const (
+	NoFeature Feature = iota
+	River
+	Beach
+)
+
+// featureCells are the palette entries of the features, for the TUI and
+// the writers in mapio.
+var featureCells = map[Feature]mapio.Cell{
+	River: {Name: "river", Glyph: "≈", Color: "39"},
+	Beach: {Name: "beach", Glyph: "▒", Color: "222"},
+}
+
+const (
+	// riverEvery is how many mountain cells feed one river, up to
+	// maxRivers.
+	riverEvery = 150
+	maxRivers  = 12
+	// riverRise is the lowest height a river may start from, so they
+	// do not spring up right on the coast.
+	riverRise = 8
+	// riverSpacing keeps river sources this many cells clear of rivers
+	// already traced.
+	riverSpacing = 6
+
+	// labelSize is the smallest region that gets a name.
+	labelSize = 24
+)
+
+// Region is one connected body of water or land, as found by the labels
+// pass.
+type Region struct {
+	Kind  string // "continent", "island", "sea" or "lake"
+	Name  string // Empty when the region is too small to label
+	Water bool
+	Size  int
+	X, Y  int // Label anchor: the cell furthest from the region's shore
+}
+
+// Terrain is a finished land map with the results of the terrain passes:
+// smoothed tiles, features drawn over them and named regions.
+type Terrain struct {
+	Tileset       *Tileset
+	Width, Height int
+	Seed          int64
+	Wrap          bool
+
+	Tiles    [][]TileType
+	Features [][]Feature
+
+	// Elevation is the height field rivers run down: the cheapest climb
+	// from the nearest water, where mountains are steep. Nil until the
+	// rivers pass runs; -1 where no water can be reached.
+	Elevation [][]int
+
+	Regions []Region
+	region  [][]int // Index into Regions, -1 for undecided cells
+
+	Smoothed int // Patches the smooth pass removed
+	Rivers   int // Rivers traced
+	Beaches  int // Beach cells
+}
+
+// NewTerrain copies a finished grid of ts's tiles for the terrain passes.
+// Undecided cells should be Empty; the passes leave them alone.
+func NewTerrain(ts *Tileset, tiles [][]TileType, seed int64, wrap bool) *Terrain {
+	t := &Terrain{Tileset: ts, Height: len(tiles), Seed: seed, Wrap: wrap}
+	if t.Height > 0 {
+		t.Width = len(tiles[0])
+	}
+	t.Tiles = make([][]TileType, t.Height)
+	t.Features = make([][]Feature, t.Height)
+	for y := range tiles {
+		t.Tiles[y] = append([]TileType(nil), tiles[y]...)
+		t.Features[y] = make([]Feature, t.Width)
+	}
+	return t
+}
+
+// Terrain copies the map for the terrain passes. Call it once the map is
+// done.
+func (w *WFC) Terrain() *Terrain {
+	tiles := make([][]TileType, w.Height)
+	for y, row := range w.Grid {
+		tiles[y] = make([]TileType, w.Width)
+		for x, tile := range row {
+			if tile.Collapsed {
+				tiles[y][x] = tile.Type
+			}
+		}
+	}
+	return NewTerrain(w.Tileset, tiles, w.Seed, wrapped(w.Topology))
+}
+
+// Terrain copies the map's tiles for the terrain passes. Call it once the
+// map is done.
+func (o *Overlap) Terrain() *Terrain {
+	tiles := make([][]TileType, o.Height)
+	for y, row := range o.Grid {
+		tiles[y] = make([]TileType, o.Width)
+		for x, cell := range row {
+			if cell.Collapsed {
+				tiles[y][x] = o.Tile(cell.Type)
+			}
+		}
+	}
+	return NewTerrain(o.Tileset, tiles, o.Seed, false)
+}
+
+// Run applies the selected passes in the order of Passes.
+func (t *Terrain) Run(on map[string]bool) {
+	if on[PassSmooth] {
+		t.Smooth()
+	}
+	if on[PassRivers] {
+		t.TraceRivers()
+	}
+	if on[PassBeaches] {
+		t.LineBeaches()
+	}
+	if on[PassLabels] {
+		t.Label()
+	}
+}
+
+// neighbors calls fn with each orthogonal neighbor of (x, y), across the
+// edges when the map wraps.
+func (t *Terrain) neighbors(x, y int, fn func(nx, ny int)) {
+	for _, d := range [4][2]int{{0, -1}, {1, 0}, {0, 1}, {-1, 0}} {
+		nx, ny := x+d[0], y+d[1]
+		if t.Wrap {
+			nx, ny = (nx+t.Width)%t.Width, (ny+t.Height)%t.Height
+		} else if nx < 0 || ny < 0 || nx >= t.Width || ny >= t.Height {
+			continue
+		}
+		fn(nx, ny)
+	}
+}
+
+// Smooth erodes every tile none of whose neighbors match it into the most
+// common neighboring tile that the tileset allows there. It returns the
+// number of patches removed.
+func (t *Terrain) Smooth() int {
+	n := 0
+	for y := 0; y < t.Height; y++ {
+		for x := 0; x < t.Width; x++ {
+			tile := t.Tiles[y][x]
+			if tile == Empty {
+				continue
+			}
+			counts := map[TileType]int{}
+			t.neighbors(x, y, func(nx, ny int) { counts[t.Tiles[ny][nx]]++ })
+			if counts[tile] > 0 || len(counts) == 0 {
+				continue
+			}
+			best := Empty
+			for _, c := range t.Tileset.Types() {
+				if counts[c] > counts[best] && t.fits(x, y, c) {
+					best = c
+				}
+			}
+			if best != Empty {
+				t.Tiles[y][x] = best
+				n++
+			}
+		}
+	}
+	t.Smoothed += n
+	return n
+}
+
+// fits reports whether c is allowed next to every decided neighbor of
+// (x, y).
+func (t *Terrain) fits(x, y int, c TileType) bool {
+	ok := true
+	t.neighbors(x, y, func(nx, ny int) {
+		if n := t.Tiles[ny][nx]; n != Empty && !t.Tileset.Allows(c, n) {
+			ok = false
+		}
+	})
+	return ok
+}
+
+// climb is what stepping onto a cell adds to the height field. Tiles the
+// tileset never lets touch water, such as lava, are as steep as mountains,
+// so rivers go around them.
+func (t *Terrain) climb(tile TileType) int {
+	switch t.Tileset.Def(tile).Role {
+	case "water":
+		return 0
+	case "land":
+		return 1
+	case "mountain":
+		return 4
+	}
+	for _, w := range t.Tileset.Types() {
+		if t.Tileset.HasRole(w, "water") && t.Tileset.Allows(tile, w) {
+			return 2
+		}
+	}
+	return 4
+}
+
+// elevate derives the height field from the water outwards and returns,
+// for every cell, the neighbor one step downhill from it as y*Width+x; -1
+// for water and cells no water can be reached from. Every cell gets a
+// little random extra height so rivers meander rather than run straight.
+func (t *Terrain) elevate(rng *rand.Rand) []int {
+	size := t.Width * t.Height
+	elev, down, jitter := make([]int, size), make([]int, size), make([]int, size)
+	for i := range jitter {
+		jitter[i] = rng.Intn(3)
+	}
+	// Climbs are small whole numbers, so a queue per height beats a heap
+	var queue [][]int
+	push := func(i, h int) {
+		for len(queue) <= h {
+			queue = append(queue, nil)
+		}
+		queue[h] = append(queue[h], i)
+	}
+	for i := range elev {
+		elev[i], down[i] = -1, -1
+		if t.Tileset.HasRole(t.Tiles[i/t.Width][i%t.Width], "water") {
+			elev[i] = 0
+			push(i, 0)
+		}
+	}
+	for h := 0; h < len(queue); h++ {
+		for k := 0; k < len(queue[h]); k++ {
+			i := queue[h][k]
+			if elev[i] != h {
+				continue
+			}
+			t.neighbors(i%t.Width, i/t.Width, func(nx, ny int) {
+				n := ny*t.Width + nx
+				nh := h + t.climb(t.Tiles[ny][nx]) + jitter[n]
+				if elev[n] < 0 || nh < elev[n] {
+					elev[n], down[n] = nh, i
+					push(n, nh)
+				}
+			})
+		}
+	}
+	t.Elevation = make([][]int, t.Height)
+	for y := range t.Elevation {
+		t.Elevation[y] = elev[y*t.Width : (y+1)*t.Width]
+	}
+	return down
+}
+
+// TraceRivers derives the height field and runs rivers from mountains
+// downhill until they reach water or join another river. Sources are high
+// mountain cells picked at random from the terrain's seed, one per
+// riverEvery mountain cells. It returns the number of rivers traced.
+func (t *Terrain) TraceRivers() int {
+	rng := rand.New(rand.NewSource(t.Seed))
+	down := t.elevate(rng)
+	var sources []int
+	mountains := 0
+	for y, row := range t.Tiles {
+		for x, tile := range row {
+			if t.Tileset.HasRole(tile, "mountain") {
+				mountains++
+				if t.Elevation[y][x] >= riverRise {
+					sources = append(sources, y*t.Width+x)
+				}
+			}
+		}
+	}
+	want := min(max(mountains/riverEvery, 1), maxRivers)
+	rng.Shuffle(len(sources), func(i, j int) { sources[i], sources[j] = sources[j], sources[i] })
+	n := 0
+	for _, src := range sources {
+		if n == want {
+			break
+		}
+		if t.nearRiver(src%t.Width, src/t.Width) {
+			continue
+		}
+		for i := src; i >= 0 && t.Elevation[i/t.Width][i%t.Width] > 0; i = down[i] {
+			f := &t.Features[i/t.Width][i%t.Width]
+			if *f == River {
+				break
+			}
+			*f = River
+		}
+		n++
+	}
+	t.Rivers += n
+	return n
+}
+
+// nearRiver reports whether a river runs within riverSpacing of (x, y).
+func (t *Terrain) nearRiver(x, y int) bool {
+	for dy := -riverSpacing; dy <= riverSpacing; dy++ {
+		for dx := -riverSpacing; dx <= riverSpacing; dx++ {
+			nx, ny := x+dx, y+dy
+			if t.Wrap {
+				nx, ny = (nx%t.Width+t.Width)%t.Width, (ny%t.Height+t.Height)%t.Height
+			} else if nx < 0 || ny < 0 || nx >= t.Width || ny >= t.Height {
+				continue
+			}
+			if t.Features[ny][nx] == River {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// LineBeaches turns land cells next to water into beach, except where a
+// river runs out. It returns the number of beach cells.
+func (t *Terrain) LineBeaches() int {
+	n := 0
+	for y, row := range t.Tiles {
+		for x, tile := range row {
+			if !t.Tileset.HasRole(tile, "land") || t.Features[y][x] != NoFeature {
+				continue
+			}
+			wet := false
+			t.neighbors(x, y, func(nx, ny int) {
+				wet = wet || t.Tileset.HasRole(t.Tiles[ny][nx], "water")
+			})
+			if wet {
+				t.Features[y][x] = Beach
+				n++
+			}
+		}
+	}
+	t.Beaches += n
+	return n
+}
+
+// Label flood-fills the map into connected bodies of water and land, and
+// names those of at least labelSize cells. Water that reaches the edge of
+// an unwrapped map, or covers an eighth of it, is a sea and the rest are
+// lakes; land covering a tenth of the map is a continent and the rest are
+// islands.
+func (t *Terrain) Label() {
+	t.Regions = nil
+	t.region = make([][]int, t.Height)
+	for y := range t.region {
+		t.region[y] = make([]int, t.Width)
+		for x := range t.region[y] {
+			t.region[y][x] = -1
+		}
+	}
+	area := t.Width * t.Height
+	for y := 0; y < t.Height; y++ {
+		for x := 0; x < t.Width; x++ {
+			if t.region[y][x] >= 0 || t.Tiles[y][x] == Empty {
+				continue
+			}
+			id := len(t.Regions)
+			r := Region{Water: t.wet(x, y)}
+			edge := false
+			t.region[y][x] = id
+			stack := [][2]int{{x, y}}
+			for len(stack) > 0 {
+				c := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				r.Size++
+				edge = edge || c[0] == 0 || c[1] == 0 || c[0] == t.Width-1 || c[1] == t.Height-1
+				t.neighbors(c[0], c[1], func(nx, ny int) {
+					if t.region[ny][nx] < 0 && t.Tiles[ny][nx] != Empty && t.wet(nx, ny) == r.Water {
+						t.region[ny][nx] = id
+						stack = append(stack, [2]int{nx, ny})
+					}
+				})
+			}
+			switch {
+			case r.Water && ((edge && !t.Wrap) || r.Size*8 >= area):
+				r.Kind = "sea"
+			case r.Water:
+				r.Kind = "lake"
+			case r.Size*10 >= area:
+				r.Kind = "continent"
+			default:
+				r.Kind = "island"
+			}
+			t.Regions = append(t.Regions, r)
+		}
+	}
+	t.anchor()
+
+	rng := rand.New(rand.NewSource(t.Seed))
+	used := map[string]bool{}
+	for i := range t.Regions {
+		r := &t.Regions[i]
+		if r.Size < labelSize {
+			continue
+		}
+		name := placeName(rng)
+		for used[name] {
+			name = placeName(rng)
+		}
+		used[name] = true
+		switch r.Kind {
+		case "sea":
+			r.Name = "Sea of " + name
+		case "lake":
+			r.Name = "Lake " + name
+		case "island":
+			r.Name = name + " Isle"
+		default:
+			r.Name = name
+		}
+	}
+}
+
+// wet reports whether (x, y) belongs to the water regions.
+func (t *Terrain) wet(x, y int) bool {
+	return t.Tileset.HasRole(t.Tiles[y][x], "water")
+}
+
+// anchor places each region's label on the cell furthest from its shore,
+// so it sits inside even a winding region.
+func (t *Terrain) anchor() {
+	dist := make([][]int, t.Height)
+	var queue [][2]int
+	for y := range dist {
+		dist[y] = make([]int, t.Width)
+		for x := range dist[y] {
+			dist[y][x] = -1
+			if t.region[y][x] < 0 {
+				continue
+			}
+			shore := !t.Wrap && (x == 0 || y == 0 || x == t.Width-1 || y == t.Height-1)
+			t.neighbors(x, y, func(nx, ny int) {
+				shore = shore || t.region[ny][nx] != t.region[y][x]
+			})
+			if shore {
+				dist[y][x] = 0
+				queue = append(queue, [2]int{x, y})
+			}
+		}
+	}
+	for k := 0; k < len(queue); k++ {
+		x, y := queue[k][0], queue[k][1]
+		t.neighbors(x, y, func(nx, ny int) {
+			if dist[ny][nx] < 0 && t.region[ny][nx] == t.region[y][x] {
+				dist[ny][nx] = dist[y][x] + 1
+				queue = append(queue, [2]int{nx, ny})
+			}
+		})
+	}
+	best := make([]int, len(t.Regions))
+	for i := range best {
+		best[i] = -1
+	}
+	for y, row := range t.region {
+		for x, id := range row {
+			if id >= 0 && dist[y][x] > best[id] {
+				best[id] = dist[y][x]
+				t.Regions[id].X, t.Regions[id].Y = x, y
+			}
+		}
+	}
+}
+
+var (
+	nameHeads = []string{"Al", "Bel", "Cor", "Dun", "El", "Fal", "Gor", "Hal", "Ith", "Kar", "Lor", "Mor", "Nar", "Or", "Pel", "Quen", "Ros", "Sel", "Tor", "Ul", "Val", "Wen", "Yr", "Zan"}
+	nameTails = []string{"a", "ia", "or", "en", "ar", "is", "oth", "ur", "eth", "ion", "wyn", "mar", "dell", "hold"}
+)
+
+// placeName makes up a name from two or three syllables.
+func placeName(rng *rand.Rand) string {
+	name := nameHeads[rng.Intn(len(nameHeads))]
+	if rng.Intn(3) == 0 {
+		name += strings.ToLower(nameHeads[rng.Intn(len(nameHeads))])
+	}
+	return name + nameTails[rng.Intn(len(nameTails))]
+}
+
+// RegionAt is the index into Regions of the region holding (x, y), or -1
+// before the labels pass or for an undecided cell.
+func (t *Terrain) RegionAt(x, y int) int {
+	if t.region == nil {
+		return -1
+	}
+	return t.region[y][x]
+}
+
+// Cell is the palette entry drawn at (x, y): its feature if it has one,
+// otherwise its tile.
+func (t *Terrain) Cell(x, y int) mapio.Cell {
+	if f := t.Features[y][x]; f != NoFeature {
+		return featureCells[f]
+	}
+	if t.Tiles[y][x] == Empty {
+		return mapio.Unresolved
+	}
+	return t.Tileset.Cell(t.Tiles[y][x])
+}
+
+// Labels are the names of the labelled regions, for mapio.Map.Labels.
+func (t *Terrain) Labels() []mapio.Label {
+	var labels []mapio.Label
+	for _, r := range t.Regions {
+		if r.Name != "" {
+			labels = append(labels, mapio.Label{Text: r.Name, Kind: r.Kind, X: r.X, Y: r.Y})
+		}
+	}
+	return labels
+}
+
+// Apply draws the terrain over a snapshot of the same map: smoothed tiles,
+// features, which join the legend, and labels.
+func (t *Terrain) Apply(m *mapio.Map) {
+	for y := range m.Cells {
+		for x := range m.Cells[y] {
+			m.Cells[y][x] = t.Cell(x, y)
+		}
+	}
+	for _, f := range []Feature{River, Beach} {
+		m.Legend = append(m.Legend, featureCells[f])
+	}
+	m.Labels = t.Labels()
+}
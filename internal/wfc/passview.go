@@ -0,0 +1,116 @@
+package wfc
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+var (
+	featureStyles = map[Feature]lipgloss.Style{
+		River: lipgloss.NewStyle().Foreground(lipgloss.Color(featureCells[River].Color)),
+		Beach: lipgloss.NewStyle().Foreground(lipgloss.Color(featureCells[Beach].Color)),
+	}
+	labelStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("231")).Background(lipgloss.Color("236")).Bold(true)
+	passOnStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true)
+	passOffStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))
+)
+
+// togglePass switches the terrain pass bound to a number key. ok is false
+// for other keys.
+func (m *Model) togglePass(key string) (ok bool) {
+	if len(key) != 1 || key[0] < '1' || int(key[0]-'1') >= len(Passes()) {
+		return false
+	}
+	name := Passes()[key[0]-'1']
+	if m.passes == nil {
+		m.passes = map[string]bool{}
+	}
+	m.passes[name] = !m.passes[name]
+	m.shape()
+	return true
+}
+
+// shape runs the enabled terrain passes over the finished map. The terrain
+// is dropped while the map is unfinished or rewound, and in world mode.
+func (m *Model) shape() {
+	m.terrain = nil
+	if !m.done || m.world != nil || m.paint != nil {
+		return
+	}
+	on := false
+	for _, p := range Passes() {
+		on = on || m.passes[p]
+	}
+	if !on {
+		return
+	}
+	if m.overlap != nil {
+		m.terrain = m.overlap.Terrain()
+	} else {
+		m.terrain = m.wfc.Terrain()
+	}
+	m.terrain.Run(m.passes)
+}
+
+// terrainCell draws (x, y) of the shaped map.
+func (m Model) terrainCell(x, y int) string {
+	t := m.terrain
+	if f := t.Features[y][x]; f != NoFeature {
+		return featureStyles[f].Render(featureCells[f].Glyph)
+	}
+	if t.Tiles[y][x] == Empty {
+		return unknownStyle.Render("?")
+	}
+	return m.styles[t.Tiles[y][x]].Render(t.Tileset.Def(t.Tiles[y][x]).Glyph)
+}
+
+// labelText lays the region names out on screen, each centered on its
+// anchor and clipped to the map. Keys are screen cells.
+func (m Model) labelText() map[[2]int]rune {
+	text := map[[2]int]rune{}
+	if m.terrain == nil {
+		return text
+	}
+	for _, r := range m.terrain.Regions {
+		if r.Name == "" {
+			continue
+		}
+		name := []rune(r.Name)
+		x := (r.X-m.rollX+m.width)%m.width - len(name)/2
+		y := (r.Y - m.rollY + m.height) % m.height
+		x = max(0, min(x, m.width-len(name)))
+		for i, c := range name {
+			if x+i >= 0 {
+				text[[2]int{x + i, y}] = c
+			}
+		}
+	}
+	return text
+}
+
+// viewPasses is the status line of the terrain passes.
+func (m Model) viewPasses() string {
+	var sb strings.Builder
+	sb.WriteString("  Passes:")
+	for i, p := range Passes() {
+		style := passOffStyle
+		if m.passes[p] {
+			style = passOnStyle
+		}
+		sb.WriteString(" " + style.Render(fmt.Sprintf("[%d] %s", i+1, p)))
+	}
+	if t := m.terrain; t != nil {
+		named := 0
+		for _, r := range t.Regions {
+			if r.Name != "" {
+				named++
+			}
+		}
+		sb.WriteString(fmt.Sprintf(" | Smoothed: %d | Rivers: %d | Beach: %d | Named: %d", t.Smoothed, t.Rivers, t.Beaches, named))
+	} else if !m.done {
+		sb.WriteString(" | Run once the map is done")
+	}
+	return sb.String()
+}
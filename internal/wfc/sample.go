@@ -0,0 +1,101 @@
+package wfc
+
+import (
+	"bufio"
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+//go:embed samples/*.txt
+var sampleFS embed.FS
+
+// DefaultSample is the sample used when overlapping mode is switched on
+// without naming one.
+const DefaultSample = "island"
+
+// Sample is a small hand-drawn map for the overlapping model. Every
+// character is a tile's sketch (see TileDef), one line per row.
+type Sample struct {
+	Name   string
+	Width  int
+	Height int
+	Tiles  [][]TileType
+}
+
+// ParseSample reads an ASCII sample, resolving characters against ts.
+// Blank lines are ignored; all other lines must have the same length.
+func ParseSample(name string, r io.Reader, ts *Tileset) (*Sample, error) {
+	s := &Sample{Name: name}
+	sc := bufio.NewScanner(r)
+	line := 0
+	for sc.Scan() {
+		line++
+		text := strings.TrimRight(sc.Text(), "\r")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		if s.Width == 0 {
+			s.Width = utf8.RuneCountInString(text)
+		} else if n := utf8.RuneCountInString(text); n != s.Width {
+			return nil, fmt.Errorf("sample %s: line %d is %d wide, want %d", name, line, n, s.Width)
+		}
+		row := make([]TileType, 0, s.Width)
+		for _, r := range text {
+			t, ok := ts.LookupSketch(r)
+			if !ok {
+				return nil, fmt.Errorf("sample %s: line %d: %q is not a sketch in tileset %s", name, line, r, ts.Name)
+			}
+			row = append(row, t)
+		}
+		s.Tiles = append(s.Tiles, row)
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	s.Height = len(s.Tiles)
+	if s.Height == 0 {
+		return nil, fmt.Errorf("sample %s: empty", name)
+	}
+	return s, nil
+}
+
+// SamplePresets lists the built-in samples, default first.
+func SamplePresets() []string {
+	entries, _ := sampleFS.ReadDir("samples")
+	names := []string{DefaultSample}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".txt")
+		if name != DefaultSample {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// LoadSample loads a sample from a text file, or a built-in sample when
+// name is not a path to an existing file.
+func LoadSample(name string, ts *Tileset) (*Sample, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) && !strings.ContainsAny(name, `/\.`) {
+			return samplePreset(name, ts)
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return ParseSample(strings.TrimSuffix(path.Base(name), ".txt"), f, ts)
+}
+
+func samplePreset(name string, ts *Tileset) (*Sample, error) {
+	f, err := sampleFS.Open(path.Join("samples", name+".txt"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown sample %q (have %s)", name, strings.Join(SamplePresets(), ", "))
+	}
+	defer f.Close()
+	return ParseSample(name, f, ts)
+}
@@ -0,0 +1,111 @@
+package wfc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSample(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		w, h int
+		err  string
+	}{
+		{"square", "~~.\n~.f\n", 3, 2, ""},
+		{"blank lines", "\n~.\n\n.~\n\n", 2, 2, ""},
+		{"crlf", "~.\r\n.~\r\n", 2, 2, ""},
+		{"ragged", "~~~\n~~\n", 0, 0, "line 2 is 2 wide, want 3"},
+		{"unknown sketch", "~.\n.x\n", 0, 0, `line 2: 'x' is not a sketch`},
+		{"empty", "\n\n", 0, 0, "empty"},
+	}
+	ts := DefaultTileset()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := ParseSample(tt.name, strings.NewReader(tt.text), ts)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s.Width != tt.w || s.Height != tt.h {
+				t.Errorf("size = %dx%d, want %dx%d", s.Width, s.Height, tt.w, tt.h)
+			}
+		})
+	}
+}
+
+func TestSamplePresetsLoad(t *testing.T) {
+	for _, name := range SamplePresets() {
+		if _, err := LoadSample(name, DefaultTileset()); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+}
+
+// TestOverlapKeepsSamplePairs checks that every pair of side-by-side tiles
+// in an overlapping-model map also occurs somewhere in its sample.
+func TestOverlapKeepsSamplePairs(t *testing.T) {
+	ts := DefaultTileset()
+	sample, err := LoadSample(DefaultSample, ts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := map[[3]TileType]bool{} // a, b and whether b is below rather than right
+	for y := 0; y < sample.Height; y++ {
+		for x := 0; x < sample.Width; x++ {
+			a := sample.Tiles[y][x]
+			seen[[3]TileType{a, sample.Tiles[y][(x+1)%sample.Width], 0}] = true
+			seen[[3]TileType{a, sample.Tiles[(y+1)%sample.Height][x], 1}] = true
+		}
+	}
+
+	o, err := NewOverlap(30, 20, 5, ts, sample, DefaultPatternSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for !o.Step() {
+	}
+	m := o.Grid
+	for y := range m {
+		for x := range m[y] {
+			a := o.Tile(m[y][x].Type)
+			if x+1 < len(m[y]) && !seen[[3]TileType{a, o.Tile(m[y][x+1].Type), 0}] {
+				t.Errorf("%d,%d: pair with its right neighbor is not in the sample", x, y)
+			}
+			if y+1 < len(m) && !seen[[3]TileType{a, o.Tile(m[y+1][x].Type), 1}] {
+				t.Errorf("%d,%d: pair with the cell below is not in the sample", x, y)
+			}
+		}
+	}
+}
+
+func TestNewOverlapPatternSize(t *testing.T) {
+	sample, err := ParseSample("tiny", strings.NewReader("~.\n.~\n"), DefaultTileset())
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		n        int
+		patterns int // 0 when the size should be rejected
+	}{
+		{1, 0},
+		{2, 2},
+		{3, 0},
+	}
+	for _, tt := range tests {
+		o, err := NewOverlap(4, 4, 1, DefaultTileset(), sample, tt.n)
+		switch {
+		case tt.patterns == 0 && err == nil:
+			t.Errorf("n=%d: accepted", tt.n)
+		case tt.patterns > 0 && err != nil:
+			t.Errorf("n=%d: %v", tt.n, err)
+		case tt.patterns > 0 && o.Patterns() != tt.patterns:
+			t.Errorf("n=%d: %d patterns, want %d", tt.n, o.Patterns(), tt.patterns)
+		}
+	}
+}
@@ -0,0 +1,353 @@
+package wfc
+
+import (
+	"embed"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"atlas.games/internal/mapio"
+	"github.com/fezcode/go-piml"
+)
+
+//go:embed tilesets/*.piml
+var presetFS embed.FS
+
+// DefaultPreset is the tileset used when none is given.
+const DefaultPreset = "temperate"
+
+// TileDef describes one tile of a tileset.
+type TileDef struct {
+	ID          string
+	Glyph       string
+	Sketch      string // Stands for the tile in ASCII samples, see Sample
+	Role        string // What the terrain passes treat the tile as, see Roles
+	Color       string // xterm-256 color index
+	Bold        bool
+	Weight      int
+	SeedMin     int // Primordial seeds planted before collapse
+	SeedMax     int
+	Climate     *Climate // Where NoiseSeeding lets the tile grow; nil for anywhere
+	Description string
+	Neighbors   []string
+}
+
+// Climate is where NoiseSeeding lets a tile grow, as ranges of height and
+// moisture percentiles: height 0-35 is the lowest 35% of the map.
+type Climate struct {
+	HeightMin, HeightMax     int
+	MoistureMin, MoistureMax int
+}
+
+// near reports whether percentiles height and moisture fall in c, or
+// within margin of it.
+func (c *Climate) near(height, moisture, margin int) bool {
+	return height >= c.HeightMin-margin && height <= c.HeightMax+margin &&
+		moisture >= c.MoistureMin-margin && moisture <= c.MoistureMax+margin
+}
+
+// Roles are the parts a tile can play in the terrain passes: water is
+// where rivers end and lakes form, land gets beaches, and mountains are
+// where rivers rise.
+var Roles = []string{"water", "land", "mountain"}
+
+// Tileset is a set of tiles plus the rules for which may touch. Tiles[i]
+// describes TileType(i+1); TileType 0 is always Empty.
+type Tileset struct {
+	Name        string
+	Description string
+	Tiles       []TileDef
+
+	compat [][]bool // compat[a][b]: b may sit next to a
+}
+
+// tilesetFile is the PIML layout of a tileset file.
+type tilesetFile struct {
+	Name        string     `piml:"name"`
+	Description string     `piml:"description"`
+	Tiles       []tileFile `piml:"tiles"`
+}
+
+// tileFile is one (tile) of a tileset file. Ranges stay strings until
+// parseRange reads them, and so does the weight, so a missing (weight) can
+// be told apart from a zero one.
+type tileFile struct {
+	ID          string   `piml:"id"`
+	Glyph       string   `piml:"glyph"`
+	Sketch      string   `piml:"sketch"`
+	Role        string   `piml:"role"`
+	Color       string   `piml:"color"`
+	Weight      string   `piml:"weight"`
+	Bold        bool     `piml:"bold"`
+	Seeds       string   `piml:"seeds"`
+	Height      string   `piml:"height"`
+	Moisture    string   `piml:"moisture"`
+	Description string   `piml:"description"`
+	Neighbors   []string `piml:"neighbors"`
+}
+
+// ParseTileset reads a tileset in PIML. See tilesets/temperate.piml for the
+// format.
+func ParseTileset(r io.Reader) (*Tileset, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	var file tilesetFile
+	if err := piml.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+
+	ts := &Tileset{Name: file.Name, Description: file.Description}
+	if ts.Name == "" {
+		ts.Name = "custom"
+	}
+	for i, item := range file.Tiles {
+		def := TileDef{
+			ID:          item.ID,
+			Glyph:       item.Glyph,
+			Sketch:      item.Sketch,
+			Role:        item.Role,
+			Color:       item.Color,
+			Bold:        item.Bold,
+			Description: item.Description,
+			Neighbors:   item.Neighbors,
+		}
+		if def.ID == "" || def.Glyph == "" {
+			return nil, fmt.Errorf("tileset %s: tile %d needs an (id) and a (glyph)", ts.Name, i+1)
+		}
+		if def.Color == "" {
+			def.Color = "255"
+		}
+		if def.Sketch == "" {
+			def.Sketch = def.Glyph
+		}
+		if utf8.RuneCountInString(def.Sketch) != 1 {
+			return nil, fmt.Errorf("tileset %s: tile %s: sketch must be a single character", ts.Name, def.ID)
+		}
+		if def.Role != "" && !slices.Contains(Roles, def.Role) {
+			return nil, fmt.Errorf("tileset %s: tile %s: unknown role %q (want one of %s)", ts.Name, def.ID, def.Role, strings.Join(Roles, ", "))
+		}
+		def.Weight = 1
+		if item.Weight != "" {
+			if def.Weight, err = strconv.Atoi(strings.TrimSpace(item.Weight)); err != nil {
+				return nil, fmt.Errorf("tileset %s: tile %s: bad weight %q", ts.Name, def.ID, item.Weight)
+			}
+		}
+		if def.Weight <= 0 {
+			return nil, fmt.Errorf("tileset %s: tile %s: weight must be positive", ts.Name, def.ID)
+		}
+		seeds := item.Seeds
+		if seeds == "" {
+			seeds = "0"
+		}
+		if def.SeedMin, def.SeedMax, err = parseRange(seeds, "seed count"); err != nil {
+			return nil, fmt.Errorf("tileset %s: tile %s: %v", ts.Name, def.ID, err)
+		}
+		if item.Height != "" || item.Moisture != "" {
+			if def.Climate, err = parseClimate(item.Height, item.Moisture); err != nil {
+				return nil, fmt.Errorf("tileset %s: tile %s: %v", ts.Name, def.ID, err)
+			}
+		}
+		ts.Tiles = append(ts.Tiles, def)
+	}
+
+	if err := ts.build(); err != nil {
+		return nil, err
+	}
+	return ts, nil
+}
+
+// parseRange accepts "n" or "min-max"; what names the value in errors.
+func parseRange(s, what string) (int, int, error) {
+	lo, hi, isRange := strings.Cut(s, "-")
+	min, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return 0, 0, fmt.Errorf("bad %s %q", what, s)
+	}
+	max := min
+	if isRange {
+		if max, err = strconv.Atoi(strings.TrimSpace(hi)); err != nil {
+			return 0, 0, fmt.Errorf("bad %s %q", what, s)
+		}
+	}
+	if min < 0 || max < min {
+		return 0, 0, fmt.Errorf("bad %s %q", what, s)
+	}
+	return min, max, nil
+}
+
+// parseClimate reads the (height) and (moisture) percentile ranges of a
+// tile. Either may be left out to cover the whole range.
+func parseClimate(height, moisture string) (*Climate, error) {
+	c := &Climate{HeightMax: 100, MoistureMax: 100}
+	var err error
+	if height != "" {
+		if c.HeightMin, c.HeightMax, err = parseRange(height, "height"); err != nil {
+			return nil, err
+		}
+	}
+	if moisture != "" {
+		if c.MoistureMin, c.MoistureMax, err = parseRange(moisture, "moisture"); err != nil {
+			return nil, err
+		}
+	}
+	if c.HeightMax > 100 || c.MoistureMax > 100 {
+		return nil, fmt.Errorf("climate percentiles run from 0 to 100")
+	}
+	return c, nil
+}
+
+// build validates the tiles and resolves neighbor IDs into the symmetric
+// compatibility table.
+func (ts *Tileset) build() error {
+	if len(ts.Tiles) == 0 {
+		return fmt.Errorf("tileset %s: no tiles", ts.Name)
+	}
+	ids := map[string]TileType{}
+	sketches := map[string]string{}
+	for i, def := range ts.Tiles {
+		if _, dup := ids[def.ID]; dup {
+			return fmt.Errorf("tileset %s: duplicate tile id %q", ts.Name, def.ID)
+		}
+		ids[def.ID] = TileType(i + 1)
+		if other, dup := sketches[def.Sketch]; dup {
+			return fmt.Errorf("tileset %s: tiles %s and %s share the sketch %q", ts.Name, other, def.ID, def.Sketch)
+		}
+		sketches[def.Sketch] = def.ID
+	}
+
+	n := len(ts.Tiles) + 1
+	ts.compat = make([][]bool, n)
+	for i := range ts.compat {
+		ts.compat[i] = make([]bool, n)
+	}
+	for i, def := range ts.Tiles {
+		a := TileType(i + 1)
+		for _, id := range def.Neighbors {
+			b, ok := ids[id]
+			if !ok {
+				return fmt.Errorf("tileset %s: tile %s lists unknown neighbor %q", ts.Name, def.ID, id)
+			}
+			ts.compat[a][b] = true
+			ts.compat[b][a] = true
+		}
+	}
+	return nil
+}
+
+// Types returns every tile type of the set, in declaration order.
+func (ts *Tileset) Types() []TileType {
+	types := make([]TileType, len(ts.Tiles))
+	for i := range ts.Tiles {
+		types[i] = TileType(i + 1)
+	}
+	return types
+}
+
+// Def returns the definition of t. Empty and unknown types get a blank tile.
+func (ts *Tileset) Def(t TileType) TileDef {
+	if t <= 0 || int(t) > len(ts.Tiles) {
+		return TileDef{ID: "empty", Glyph: " ", Color: "0"}
+	}
+	return ts.Tiles[t-1]
+}
+
+// LookupSketch finds a tile type by its sketch character.
+func (ts *Tileset) LookupSketch(r rune) (TileType, bool) {
+	for i, def := range ts.Tiles {
+		if def.Sketch == string(r) {
+			return TileType(i + 1), true
+		}
+	}
+	return Empty, false
+}
+
+// HasRole reports whether t plays role in the terrain passes.
+func (ts *Tileset) HasRole(t TileType, role string) bool {
+	return role != "" && ts.Def(t).Role == role
+}
+
+// Lookup finds a tile type by its ID.
+func (ts *Tileset) Lookup(id string) (TileType, bool) {
+	for i, def := range ts.Tiles {
+		if def.ID == id {
+			return TileType(i + 1), true
+		}
+	}
+	return Empty, false
+}
+
+// Allows reports whether b may sit next to a.
+func (ts *Tileset) Allows(a, b TileType) bool {
+	if int(a) >= len(ts.compat) || int(b) >= len(ts.compat) {
+		return false
+	}
+	return ts.compat[a][b]
+}
+
+// Cell converts t for the writers in mapio.
+func (ts *Tileset) Cell(t TileType) mapio.Cell {
+	def := ts.Def(t)
+	return mapio.Cell{Name: def.ID, Glyph: def.Glyph, Color: def.Color}
+}
+
+// Presets lists the built-in tilesets, default first.
+func Presets() []string {
+	entries, _ := presetFS.ReadDir("tilesets")
+	names := []string{DefaultPreset}
+	for _, e := range entries {
+		name := strings.TrimSuffix(e.Name(), ".piml")
+		if name != DefaultPreset {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// Preset loads a built-in tileset by name.
+func Preset(name string) (*Tileset, error) {
+	f, err := presetFS.Open(path.Join("tilesets", name+".piml"))
+	if err != nil {
+		return nil, fmt.Errorf("unknown tileset preset %q (have %s)", name, strings.Join(Presets(), ", "))
+	}
+	defer f.Close()
+	return ParseTileset(f)
+}
+
+// LoadTileset loads a tileset from a .piml file, or a built-in preset when
+// name is not a path to an existing file.
+func LoadTileset(name string) (*Tileset, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		if os.IsNotExist(err) && !strings.ContainsAny(name, `/\.`) {
+			return Preset(name)
+		}
+		return nil, err
+	}
+	defer f.Close()
+	return ParseTileset(f)
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultTileset *Tileset
+)
+
+// DefaultTileset returns the built-in temperate tileset.
+func DefaultTileset() *Tileset {
+	defaultOnce.Do(func() {
+		ts, err := Preset(DefaultPreset)
+		if err != nil {
+			panic("wfc: broken default tileset: " + err.Error())
+		}
+		defaultTileset = ts
+	})
+	return defaultTileset
+}
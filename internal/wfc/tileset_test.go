@@ -0,0 +1,90 @@
+package wfc
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPresetsLoad(t *testing.T) {
+	for _, name := range Presets() {
+		t.Run(name, func(t *testing.T) {
+			ts, err := Preset(name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ts.Name != name {
+				t.Errorf("Name = %q, want %q", ts.Name, name)
+			}
+			for _, tt := range ts.Types() {
+				def := ts.Def(tt)
+				if def.Weight <= 0 || def.Color == "" || def.Sketch == "" {
+					t.Errorf("tile %s: weight %d, color %q, sketch %q", def.ID, def.Weight, def.Color, def.Sketch)
+				}
+			}
+		})
+	}
+}
+
+func TestParseTilesetFields(t *testing.T) {
+	ts, err := Preset(DefaultPreset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	water, ok := ts.Lookup("water")
+	if !ok {
+		t.Fatal("no water tile")
+	}
+	def := ts.Def(water)
+	if def.Glyph != "~" || def.Color != "33" || def.Weight != 35 || def.SeedMin != 2 || def.SeedMax != 4 || def.Role != "water" {
+		t.Errorf("water = %+v", def)
+	}
+	if def.Climate == nil || def.Climate.HeightMin != 0 || def.Climate.HeightMax != 38 {
+		t.Errorf("water climate = %+v", def.Climate)
+	}
+	land, _ := ts.Lookup("land")
+	if !ts.Allows(water, land) || !ts.Allows(land, water) {
+		t.Error("water and land should be allowed next to each other")
+	}
+}
+
+// tile is a one-tile tileset with the given extra fields.
+func tile(fields string) string {
+	return "(name) test\n(tiles)\n  > (tile)\n    (id) a\n    (glyph) a\n" + fields + "    (neighbors)\n      > a\n"
+}
+
+func TestParseTilesetErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		doc    string
+		weight int    // Wanted weight of the tile when parsing succeeds
+		err    string // Wanted error text, empty for none
+	}{
+		{"default weight", tile(""), 1, ""},
+		{"weight", tile("    (weight) 7\n"), 7, ""},
+		{"zero weight", tile("    (weight) 0\n"), 0, "weight must be positive"},
+		{"negative weight", tile("    (weight) -3\n"), 0, "weight must be positive"},
+		{"bad weight", tile("    (weight) heavy\n"), 0, "bad weight"},
+		{"bad seeds", tile("    (seeds) 4-2\n"), 0, "bad seed count"},
+		{"bad role", tile("    (role) lava\n"), 0, "unknown role"},
+		{"long sketch", tile("    (sketch) ab\n"), 0, "single character"},
+		{"no glyph", "(tiles)\n  > (tile)\n    (id) a\n", 0, "needs an (id) and a (glyph)"},
+		{"no tiles", "(name) empty\n", 0, "no tiles"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts, err := ParseTileset(strings.NewReader(tt.doc))
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("err = %v, want one containing %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ts.Def(ts.Types()[0]).Weight; got != tt.weight {
+				t.Errorf("weight = %d, want %d", got, tt.weight)
+			}
+		})
+	}
+}
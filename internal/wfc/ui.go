@@ -1,22 +1,26 @@
 package wfc
 
 import (
+	"fmt"
 	"strings"
 	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"atlas.games/internal/mapio"
+	"atlas.games/internal/registry"
+	"atlas.games/internal/solver"
 )
 
 var (
-	waterStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("33"))
-	landStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("185"))
-	forestStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("34"))
-	mountainStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("255")).Bold(true) // White Mountains
-	lavaStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("196")).Bold(true)
 	titleStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true)
+	unknownStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("235"))
 )
 
+// panStep is how many columns an arrow key scrolls the world; rows move
+// half as far since terminal cells are tall.
+const panStep = 8
+
 type tickMsg time.Time
 
 type Model struct {
@@ -25,18 +29,87 @@ type Model struct {
 	width       int
 	height      int
 	showingHelp bool
+
+	presets []string
+	preset  int
+	styles  map[TileType]lipgloss.Style
+
+	// Topology of the tileset-rules map. A wrapped map can be rolled with
+	// the arrow keys, showing from column rollX and row rollY, to check
+	// that it tiles.
+	topo         solver.Topology
+	rollX, rollY int
+	seeding      Seeding
+
+	// Overlapping mode. sample is an index into samples plus one; 0 runs
+	// the tileset's own rules.
+	samples []string
+	sample  int
+	overlap *Overlap
+
+	// World mode: an endless chunked map, viewed from tile (camX, camY)
+	world      *World
+	camX, camY int
+
+	// Timeline playback, see playback.go. speed indexes speeds.
+	paused bool
+	speed  int
+
+	// Heat-map overlay and inspector cursor, in screen cells; see
+	// overlay.go
+	overlay    int
+	curX, curY int
+
+	// Paint mode: pins laid down by hand before the land is solved. pins
+	// outlive paint mode so R keeps regenerating around them.
+	pins  *Constraints
+	paint *painter
+
+	// Terrain passes enabled with the number keys, and their result once
+	// the map is done; see passes.go
+	passes  map[string]bool
+	terrain *Terrain
+
+	notice string // Result of the last export
 }
 
-func NewModel() Model {
+func init() {
+	registry.Register(registry.Game{
+		ID:          "wfc-land",
+		Title:       "WFC Land Creator",
+		Description: "Procedural terrain generation using Wave Function Collapse.",
+		Category:    registry.Generators,
+		Order:       1,
+		New:         func(seed int64) tea.Model { return NewModel(seed) },
+	})
+}
+
+func NewModel(seed int64) Model {
 	// Massive grid
 	w, h := 200, 60
+	ts := DefaultTileset()
 	return Model{
-		wfc:    NewWFC(w, h),
-		width:  w,
-		height: h,
+		wfc:     NewWFC(w, h, seed, nil, ts),
+		topo:    solver.Square{},
+		speed:   defaultSpeed,
+		width:   w,
+		height:  h,
+		presets: Presets(),
+		styles:  tileStyles(ts),
+		samples: SamplePresets(),
 	}
 }
 
+// tileStyles builds the lipgloss style of every tile in ts.
+func tileStyles(ts *Tileset) map[TileType]lipgloss.Style {
+	styles := map[TileType]lipgloss.Style{}
+	for _, t := range ts.Types() {
+		def := ts.Def(t)
+		styles[t] = lipgloss.NewStyle().Foreground(lipgloss.Color(def.Color)).Bold(def.Bold)
+	}
+	return styles
+}
+
 func (m Model) Init() tea.Cmd {
 	return tick()
 }
@@ -44,28 +117,167 @@ func (m Model) Init() tea.Cmd {
 func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.paint != nil {
+			switch msg.String() {
+			case "q", "ctrl+c", "esc":
+				return m, tea.Quit
+			case "p":
+				m.paint = nil
+				return m, tick()
+			}
+			if m.updatePaint(msg) {
+				m.paint = nil
+				m.restart(time.Now().UnixNano())
+				return m, tick()
+			}
+			return m, nil
+		}
 		switch msg.String() {
 		case "q", "ctrl+c", "esc":
 			return m, tea.Quit
+		case "p":
+			// Pins are painted onto the tileset rules, not samples or worlds
+			m.world = nil
+			m.sample, m.overlap = 0, nil
+			if m.pins == nil || m.pins.Tileset != m.wfc.Tileset {
+				m.pins = NewConstraints(m.width, m.height, m.wfc.Tileset)
+			}
+			m.paint = newPainter(m.pins)
+			m.showingHelp = false
+			m.notice = ""
+			return m, nil
 		case "r":
-			m.wfc = NewWFC(m.width, m.height)
-			m.done = false
+			m.restart(time.Now().UnixNano())
 			m.showingHelp = false
+			m.notice = ""
 			return m, tick()
+		case "o":
+			m.world = nil
+			// Tileset rules, then each built-in sample, then back again
+			m.sample = (m.sample + 1) % (len(m.samples) + 1)
+			m.restart(m.seed())
+			return m, tick()
+		case "t":
+			if m.overlap != nil {
+				// Samples are drawn in the default tileset's sketches
+				return m, nil
+			}
+			// Same seed, next preset, so palettes can be compared side by side
+			next := (m.preset + 1) % len(m.presets)
+			ts, err := Preset(m.presets[next])
+			if err != nil {
+				return m, nil
+			}
+			m.preset = next
+			if m.pins != nil && m.pins.Len() > 0 {
+				// Pins name tiles of the old tileset
+				m.pins = nil
+				m.notice = "Cleared pins painted for " + m.wfc.Tileset.Name
+			}
+			m.wfc = NewSeededWFC(m.width, m.height, m.seed(), m.topo, ts, m.seeding, nil)
+			m.styles = tileStyles(ts)
+			m.done = false
+			m.terrain = nil
+			if m.world != nil {
+				m.restart(m.world.Seed)
+			}
+			return m, tick()
+		case "w":
+			if m.world != nil {
+				seed := m.world.Seed
+				m.world = nil
+				m.restart(seed)
+				return m, tick()
+			}
+			m.sample, m.overlap = 0, nil
+			m.world = NewWorld(m.seed(), m.wfc.Tileset)
+			m.overlay = overlayOff
+			// Start centred on the origin
+			m.camX, m.camY = -m.width/2, -m.height/2
+			m.restart(m.world.Seed)
+			return m, nil
+		case "n", "a":
+			if m.world != nil || m.overlap != nil {
+				// Chunks and samples are solved on the square grid
+				return m, nil
+			}
+			// Same seed, so the rules can be compared side by side
+			_, moore := m.topo.(solver.Moore)
+			wrap := wrapped(m.topo)
+			if msg.String() == "n" {
+				moore = !moore
+			} else {
+				wrap = !wrap
+			}
+			m.topo = solver.Square{Wrap: wrap}
+			if moore {
+				m.topo = solver.Moore{Wrap: wrap}
+			}
+			m.restart(m.seed())
+			return m, tick()
+		case "s":
+			if m.world != nil || m.overlap != nil {
+				// Chunks and samples have seeding of their own
+				return m, nil
+			}
+			// Same seed, so the seedings can be compared side by side
+			m.seeding = (m.seeding + 1) % Seeding(len(Seedings))
+			m.restart(m.seed())
+			return m, tick()
+		case "v":
+			if m.world != nil {
+				return m, nil
+			}
+			m.overlay = (m.overlay + 1) % overlayModes
+			return m, nil
+		case "up", "down", "left", "right":
+			if m.overlay != overlayOff && m.world == nil {
+				m.moveCursor(msg.String())
+				return m, nil
+			}
+			if m.world == nil {
+				if m.overlap == nil && wrapped(m.topo) {
+					m.roll(msg.String())
+				}
+				return m, nil
+			}
+			switch msg.String() {
+			case "up":
+				m.camY -= panStep / 2
+			case "down":
+				m.camY += panStep / 2
+			case "left":
+				m.camX -= panStep
+			case "right":
+				m.camX += panStep
+			}
+			m.world.Ensure(m.camX, m.camY, m.width, m.height)
+			return m, nil
+		case "e":
+			m.notice = export(m.snapshot(), "png")
+			return m, nil
 		case "h":
 			m.showingHelp = !m.showingHelp
 			return m, nil
+		default:
+			if m.world == nil && m.togglePass(msg.String()) {
+				return m, nil
+			}
+			if cmd, ok := m.updatePlayback(msg.String()); ok {
+				m.shape()
+				return m, cmd
+			}
 		}
 
 	case tickMsg:
-		if !m.done && !m.showingHelp {
-			// Higher burst for massive grid
-			for i := 0; i < 50; i++ {
-				m.done = m.wfc.Step()
+		if !m.done && !m.showingHelp && m.paint == nil && !m.paused {
+			for i := 0; i < speeds[m.speed]; i++ {
+				m.done = m.step()
 				if m.done {
 					break
 				}
 			}
+			m.shape()
 			return m, tick()
 		}
 	}
@@ -73,6 +285,128 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	return m, nil
 }
 
+// restart rebuilds the active generator with seed. A sample that fails to
+// load drops back to the tileset rules.
+func (m *Model) restart(seed int64) {
+	m.rollX, m.rollY = 0, 0
+	m.terrain = nil
+	if m.world != nil {
+		// Chunks are generated on demand rather than stepped
+		m.world = NewWorld(seed, m.wfc.Tileset)
+		m.world.Ensure(m.camX, m.camY, m.width, m.height)
+		m.styles = tileStyles(m.world.Tileset)
+		m.done = true
+		return
+	}
+	m.done = false
+	m.overlap = nil
+	if m.sample > 0 {
+		ts := DefaultTileset()
+		sample, err := LoadSample(m.samples[m.sample-1], ts)
+		if err == nil {
+			m.overlap, err = NewOverlap(m.width, m.height, seed, ts, sample, DefaultPatternSize)
+		}
+		if err == nil {
+			m.styles = tileStyles(ts)
+			return
+		}
+		m.sample = 0
+	}
+	var pins *Constraints
+	if m.pins != nil && m.pins.Len() > 0 {
+		pins = m.pins
+	}
+	m.wfc = NewSeededWFC(m.width, m.height, seed, m.topo, m.wfc.Tileset, m.seeding, pins)
+	m.styles = tileStyles(m.wfc.Tileset)
+}
+
+// wrapped reports whether topo joins opposite edges.
+func wrapped(topo solver.Topology) bool {
+	switch t := topo.(type) {
+	case solver.Square:
+		return t.Wrap
+	case solver.Moore:
+		return t.Wrap
+	}
+	return false
+}
+
+// roll scrolls a wrapped map by panStep in the direction of key, so the
+// seams can be inspected anywhere on screen.
+func (m *Model) roll(key string) {
+	switch key {
+	case "up":
+		m.rollY -= panStep / 2
+	case "down":
+		m.rollY += panStep / 2
+	case "left":
+		m.rollX -= panStep
+	case "right":
+		m.rollX += panStep
+	}
+	m.rollX = (m.rollX%m.width + m.width) % m.width
+	m.rollY = (m.rollY%m.height + m.height) % m.height
+}
+
+// seed is the seed of the map on screen.
+func (m Model) seed() int64 {
+	if m.world != nil {
+		return m.world.Seed
+	}
+	if m.overlap != nil {
+		return m.overlap.Seed
+	}
+	return m.wfc.Seed
+}
+
+func (m Model) step() bool {
+	if m.world != nil {
+		return true
+	}
+	return m.player().Forward()
+}
+
+// tileAt is the tile shown at (x, y); ok is false while it is undecided.
+func (m Model) tileAt(x, y int) (t TileType, ok bool) {
+	if m.world != nil {
+		return m.world.TileAt(m.camX+x, m.camY+y), true
+	}
+	if m.overlap != nil {
+		cell := m.overlap.Record().Grid()[y][x]
+		if !cell.Collapsed {
+			return Empty, false
+		}
+		return m.overlap.Tile(cell.Type), true
+	}
+	tile := m.wfc.Record().Grid()[(y+m.rollY)%m.height][(x+m.rollX)%m.width]
+	return tile.Type, tile.Collapsed
+}
+
+// snapshot is the map on screen for the writers in mapio.
+func (m Model) snapshot() *mapio.Map {
+	if m.world != nil {
+		return m.world.Map(m.camX, m.camY, m.width, m.height)
+	}
+	var mp *mapio.Map
+	if m.overlap != nil {
+		mp = m.overlap.Map()
+	} else {
+		mp = m.wfc.Map()
+	}
+	if m.terrain != nil {
+		m.terrain.Apply(mp)
+	}
+	return mp
+}
+
+// tileset is the palette the current map is drawn with.
+func (m Model) tileset() *Tileset {
+	if m.overlap != nil {
+		return m.overlap.Tileset
+	}
+	return m.wfc.Tileset
+}
+
 func (m Model) View() string {
 	if m.showingHelp {
 		var sb strings.Builder
@@ -81,53 +415,131 @@ func (m Model) View() string {
 		sb.WriteString("  1. " + lipgloss.NewStyle().Bold(true).Render("ENTROPY:") + " Every cell starts in a state of 'Superposition'.\n")
 		sb.WriteString("  2. " + lipgloss.NewStyle().Bold(true).Render("OBSERVATION:") + " The system collapses cells based on weighted probability.\n")
 		sb.WriteString("  3. " + lipgloss.NewStyle().Bold(true).Render("PROPAGATION:") + " Decisions ripple to neighbors, enforcing biome logic.\n")
-		sb.WriteString("  4. " + lipgloss.NewStyle().Bold(true).Render("SEEDING:") + " Every map starts with 'Primordial Seeds' of all biomes to ensure diversity.\n\n")
+		sb.WriteString("  4. " + lipgloss.NewStyle().Bold(true).Render("SEEDING:") + " Every map starts with 'Primordial Seeds' of all biomes to ensure diversity.\n")
+		sb.WriteString("  5. " + lipgloss.NewStyle().Bold(true).Render("BACKTRACKING:") + " A contradiction rolls back recent collapses; if that fails the map restarts.\n")
+		sb.WriteString("  6. " + lipgloss.NewStyle().Bold(true).Render("SAMPLES:") + " [O] learns 3x3 patterns from a hand-drawn map and grows new land from them.\n")
+		sb.WriteString("  7. " + lipgloss.NewStyle().Bold(true).Render("WORLD:") + " [W] explores an endless map solved chunk by chunk, each matched to its neighbors' borders.\n")
+		sb.WriteString("  8. " + lipgloss.NewStyle().Bold(true).Render("PAINT:") + " [P] pins tiles and brush strokes by hand; the solver fills in the rest around them.\n")
+		sb.WriteString("  9. " + lipgloss.NewStyle().Bold(true).Render("TOPOLOGY:") + " [N] adds diagonal neighbors to the rules; [A] wraps the edges so the map tiles, and the arrows roll it.\n")
+		sb.WriteString("  10. " + lipgloss.NewStyle().Bold(true).Render("TIMELINE:") + " Every step is recorded. [Space] pauses, [,] and [.] step back and forth, [[] and []] scrub.\n")
+		sb.WriteString("      The cell just collapsed is " + lipgloss.NewStyle().Background(observedBg).Render("highlighted") + "; neighbors whose possibilities shrank are " + lipgloss.NewStyle().Background(shrunkBg).Render("shaded") + ".\n")
+		sb.WriteString("  11. " + lipgloss.NewStyle().Bold(true).Render("HEAT MAP:") + " [V] colors undecided cells by possibilities left, then by entropy, from " + heat(1, 0) + " to " + heat(9, 1) + ".\n")
+		sb.WriteString(fmt.Sprintf("      The arrows move a cursor; the inspector lists its candidates with weights and the %dx neighbor bonus.\n", neighborBonus))
+		sb.WriteString("  12. " + lipgloss.NewStyle().Bold(true).Render("NOISE:") + " [S] swaps random seeds for height and moisture noise, so water fills the lowlands and lava the peaks.\n")
+		sb.WriteString("  13. " + lipgloss.NewStyle().Bold(true).Render("PASSES:") + " [1] smooths one-tile specks, [2] runs " + featureStyles[River].Render("rivers") + " downhill from mountains to water,\n")
+		sb.WriteString("      [3] lines coasts with " + featureStyles[Beach].Render("beaches") + " and [4] names continents, islands, seas and lakes, once the map is done.\n\n")
 
-		sb.WriteString("  " + lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true).Render("THE BIOMES") + "\n")
-		sb.WriteString("  " + waterStyle.Render("~ Water   ") + ": Expansive oceans and lakes.\n")
-		sb.WriteString("  " + landStyle.Render("█ Land    ") + ": The primary substrate (Solid Block).\n")
-		sb.WriteString("  " + forestStyle.Render("↑ Forest  ") + ": Dense wooded clusters.\n")
-		sb.WriteString("  " + mountainStyle.Render("▲ Mountain") + ": Jagged ridges and peaks (White Peaks).\n")
-		sb.WriteString("  " + lavaStyle.Render("░ Lava    ") + ": Volcanic flows near mountains.\n\n")
+		ts := m.tileset()
+		sb.WriteString("  " + lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true).Render("THE BIOMES") + " (" + ts.Name + ": " + ts.Description + ")\n")
+		for _, t := range ts.Types() {
+			def := ts.Def(t)
+			label := fmt.Sprintf("%s %-9s", def.Glyph, strings.ToUpper(def.ID[:1])+def.ID[1:])
+			sb.WriteString("  " + m.styles[t].Render(label) + ": " + def.Description + "\n")
+		}
+		sb.WriteString("\n")
 
 		sb.WriteString("  " + lipgloss.NewStyle().Foreground(lipgloss.Color("214")).Bold(true).Render("CONTROLS") + "\n")
-		sb.WriteString("  [R] Reset Map  [H] Close Documentation  [Q] Exit to Launcher\n")
+		sb.WriteString("  [R] Reset Map  [T] Next Tileset  [O] Next Sample  [W] World  [Arrows] Pan World  [P] Paint Pins  [N] 4/8 Neighbors  [A] Wrap Edges  [S] Seeding  [Space] Pause  [,/.] Step  [[/]] Scrub  [-/+] Speed  [V] Heat Map  [1-4] Passes  [E] Export PNG  [H] Close Documentation  [Q] Exit to Launcher\n")
 		return sb.String()
 	}
 
+	if m.paint != nil {
+		return m.viewPaint()
+	}
+
 	var sb strings.Builder
 	sb.WriteString("  " + titleStyle.Render(" ATLAS LANDSCAPE ENGINE - LAND CREATOR ") + "\n")
 
+	p := m.player()
+	marks := solver.Marks{X: -1, Y: -1}
+	if p != nil {
+		marks = p.Marks()
+	}
+	labels := m.labelText()
 	for y := 0; y < m.height; y++ {
 		sb.WriteString("  ")
 		for x := 0; x < m.width; x++ {
-			tile := m.wfc.Grid[y][x]
-			if !tile.Collapsed {
-				sb.WriteString(lipgloss.NewStyle().Foreground(lipgloss.Color("235")).Render("?"))
-			} else {
-				switch tile.Type {
-				case Water:
-					sb.WriteString(waterStyle.Render("~"))
-				case Land:
-					sb.WriteString(landStyle.Render("█"))
-				case Forest:
-					sb.WriteString(forestStyle.Render("↑"))
-				case Mountain:
-					sb.WriteString(mountainStyle.Render("▲"))
-				case Lava:
-					sb.WriteString(lavaStyle.Render("░"))
-				default:
-					sb.WriteString(" ")
+			gx, gy := (x+m.rollX)%m.width, (y+m.rollY)%m.height
+			if m.overlay != overlayOff && x == m.curX && y == m.curY {
+				t, ok := m.tileAt(x, y)
+				glyph := "?"
+				if ok {
+					glyph = m.tileset().Def(t).Glyph
 				}
+				sb.WriteString(cursorStyle.Render(glyph))
+				continue
+			}
+			if c, ok := labels[[2]int{x, y}]; ok {
+				sb.WriteString(labelStyle.Render(string(c)))
+				continue
+			}
+			if cell, ok := m.overlayCell(gx, gy); ok {
+				sb.WriteString(cell)
+				continue
+			}
+			if m.terrain != nil {
+				sb.WriteString(m.terrainCell(gx, gy))
+				continue
+			}
+			t, ok := m.tileAt(x, y)
+			if !ok {
+				sb.WriteString(marked(unknownStyle, marks, gx, gy).Render("?"))
+			} else {
+				sb.WriteString(marked(m.styles[t], marks, gx, gy).Render(m.tileset().Def(t).Glyph))
 			}
 		}
 		sb.WriteString("\n")
 	}
 
-	sb.WriteString("  [R] Reset Map  [H] Help  [Q] Exit to Launcher")
+	if m.world != nil {
+		wd := m.world
+		sb.WriteString(fmt.Sprintf("  Seed: %d | Tileset: %s | View: %d,%d | Chunks: %d | Seams: %d | [Arrows] Pan  [R] New World  [T] Tileset  [W] Leave World  [E] Export  [H] Help  [Q] Exit to Launcher", wd.Seed, wd.Tileset.Name, m.camX, m.camY, wd.Chunks(), wd.Seams))
+	} else if m.overlap != nil {
+		o := m.overlap
+		sb.WriteString(fmt.Sprintf("  Seed: %d | Sample: %s (%d patterns) | Backtracks: %d | Restarts: %d | [R] Reset Map  [O] Sample  [E] Export  [H] Help  [Q] Exit to Launcher", o.Seed, o.Sample.Name, o.Patterns(), o.Backtracks, o.Restarts))
+	} else {
+		pins := ""
+		if m.pins != nil && m.pins.Len() > 0 {
+			pins = fmt.Sprintf(" | Pins: %d (%d rejected)", m.pins.Len(), m.wfc.Rejected)
+		}
+		roll := ""
+		if wrapped(m.topo) {
+			roll = fmt.Sprintf(" | Roll: %d,%d", m.rollX, m.rollY)
+		}
+		sb.WriteString(fmt.Sprintf("  Seed: %d | Tileset: %s | Grid: %s%s | Seeding: %s%s | Backtracks: %d | Restarts: %d | [R] Reset  [T] Tileset  [N] Neighbors  [A] Wrap  [S] Seeding  [O] Sample  [W] World  [P] Paint  [E] Export  [H] Help  [Q] Exit", m.wfc.Seed, m.wfc.Tileset.Name, solver.Describe(m.topo), roll, m.seeding, pins, m.wfc.Backtracks, m.wfc.Restarts))
+	}
+	if p != nil {
+		sb.WriteString("\n" + viewTimeline(p, marks, m.paused, m.speed, m.tileName))
+		sb.WriteString("\n" + m.viewPasses())
+	}
+	if m.overlay != overlayOff && m.world == nil {
+		sb.WriteString(fmt.Sprintf("\n  Overlay: %s | [V] Cycle  [Arrows] Move Cursor\n", overlayNames[m.overlay]))
+		sb.WriteString(m.inspect())
+	}
+	if m.notice != "" {
+		sb.WriteString("\n  " + m.notice)
+	}
 	return sb.String()
 }
 
+// tileName names tile t of the solver on screen, for the timeline.
+func (m Model) tileName(t int) string {
+	if m.overlap != nil {
+		return m.overlap.Tileset.Def(m.overlap.Tile(Pattern(t))).ID
+	}
+	return m.wfc.Tileset.Def(TileType(t)).ID
+}
+
+// export saves the map on screen in the working directory and reports the
+// result for the status line.
+func export(m *mapio.Map, formats ...string) string {
+	names, err := mapio.SaveAs(m, mapio.Options{Scale: mapio.DefaultScale}, formats...)
+	if err != nil {
+		return "Export failed: " + err.Error()
+	}
+	return "Saved " + strings.Join(names, ", ")
+}
+
 func tick() tea.Cmd {
 	return tea.Every(time.Millisecond*10, func(t time.Time) tea.Msg {
 		return tickMsg(t)
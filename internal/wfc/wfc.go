@@ -1,10 +1,13 @@
 package wfc
 
 import (
-	"math/rand"
-	"time"
+	"slices"
+
+	"atlas.games/internal/solver"
 )
 
+// TileType indexes into the active Tileset; 0 is always Empty. The named
+// constants are the tiles of the default temperate tileset.
 type TileType int
 
 const (
@@ -16,209 +19,86 @@ const (
 	Lava
 )
 
-var weights = map[TileType]int{
-	Land:     50,
-	Water:    35,
-	Forest:   30,
-	Mountain: 20,
-	Lava:     12,
-}
+// Tile is one grid cell.
+type Tile = solver.Tile[TileType]
 
-type Tile struct {
-	Type          TileType
-	Possibilities []TileType
-	Collapsed     bool
-}
+// neighborBonus favours a tile already collapsed next door, so biomes grow
+// into patches instead of speckle.
+const neighborBonus = 8
 
+// WFC is the land generator: the tileset's rules running on the shared
+// solver.
 type WFC struct {
-	Width  int
-	Height int
-	Grid   [][]Tile
-}
-
-func NewWFC(width, height int) *WFC {
-	rand.Seed(time.Now().UnixNano())
-	w := &WFC{
-		Width:  width,
-		Height: height,
-		Grid:   make([][]Tile, height),
-	}
-
-	allTypes := []TileType{Water, Land, Forest, Mountain, Lava}
-
-	for y := 0; y < height; y++ {
-		w.Grid[y] = make([]Tile, 0, width)
-		for x := 0; x < width; x++ {
-			w.Grid[y] = append(w.Grid[y], Tile{
-				Possibilities: append([]TileType{}, allTypes...),
-				Collapsed:     false,
-			})
-		}
-	}
-
-	// SEEDING: Plant random biomes to ensure diversity
-	biomes := []TileType{Water, Forest, Mountain, Lava}
-	for _, b := range biomes {
-		numSeeds := 2 + rand.Intn(3)
-		for i := 0; i < numSeeds; i++ {
-			sx, sy := rand.Intn(width), rand.Intn(height)
-			w.collapseTile(sx, sy, b)
-		}
-	}
-
-	return w
-}
-
-func (w *WFC) collapseTile(x, y int, t TileType) {
-	tile := &w.Grid[y][x]
-	if tile.Collapsed {
-		return
-	}
-	tile.Type = t
-	tile.Collapsed = true
-	tile.Possibilities = []TileType{t}
-	w.Propagate(x, y)
+	*solver.Solver[TileType]
+	Tileset  *Tileset
+	Seeding  Seeding
+	Rejected int // Painted pins skipped because they clashed, see NewSeededWFC
 }
 
-func (w *WFC) GetEntropy(x, y int) int {
-	if w.Grid[y][x].Collapsed {
-		return 999
-	}
-	return len(w.Grid[y][x].Possibilities)
+// NewWFC prepares a width x height map. A nil topology selects
+// solver.Square and a nil tileset the default. Tileset rules hold in every
+// direction, so they work unchanged on any topology.
+func NewWFC(width, height int, seed int64, topo solver.Topology, ts *Tileset) *WFC {
+	return NewSeededWFC(width, height, seed, topo, ts, UniformSeeding, nil)
 }
 
-func (w *WFC) Collapse() bool {
-	minEntropy := 999
-	var candidates [][2]int
-
-	for y := 0; y < w.Height; y++ {
-		for x := 0; x < w.Width; x++ {
-			e := w.GetEntropy(x, y)
-			if e < minEntropy {
-				minEntropy = e
-				candidates = [][2]int{{x, y}}
-			} else if e == minEntropy {
-				candidates = append(candidates, [2]int{x, y})
-			}
-		}
-	}
-
-	if minEntropy == 999 {
-		return true 
-	}
-
-	c := candidates[rand.Intn(len(candidates))]
-	x, y := c[0], c[1]
-
-	tile := &w.Grid[y][x]
-	if len(tile.Possibilities) == 0 {
-		return false
-	}
-
-	typeWeights := make(map[TileType]int)
-	for _, p := range tile.Possibilities {
-		typeWeights[p] = weights[p]
+// NewSeededWFC is NewWFC with a choice of seeding and, unless c is nil,
+// painted pins. The pins are placed before the random biome seeds, so the
+// solver fills in the rest around them. Pins outside the map are dropped;
+// pins that clash with earlier ones are skipped and counted in Rejected.
+func NewSeededWFC(width, height int, seed int64, topo solver.Topology, ts *Tileset, seeding Seeding, c *Constraints) *WFC {
+	if ts == nil {
+		ts = DefaultTileset()
 	}
-
-	// Neighbor Bonus
-	neighbors := [][2]int{{x, y - 1}, {x, y + 1}, {x - 1, y}, {x + 1, y}}
-	for _, n := range neighbors {
-		nx, ny := n[0], n[1]
-		if nx >= 0 && nx < w.Width && ny >= 0 && ny < w.Height {
-			neighbor := w.Grid[ny][nx]
-			if neighbor.Collapsed {
-				if _, ok := typeWeights[neighbor.Type]; ok {
-					typeWeights[neighbor.Type] *= 8 
+	w := &WFC{Tileset: ts, Seeding: seeding}
+	w.Solver = solver.New(width, height, seed, topo, landRules(ts, func(s *solver.Solver[TileType]) {
+		w.Rejected = 0
+		if c != nil {
+			for _, p := range c.Pins() {
+				if p.X >= width || p.Y >= height {
+					continue
+				}
+				if !s.Pin(p.X, p.Y, p.Tile) {
+					w.Rejected++
 				}
 			}
 		}
-	}
-
-	totalWeight := 0
-	for _, weight := range typeWeights {
-		totalWeight += weight
-	}
-
-	if totalWeight == 0 { return false }
-
-	pick := rand.Intn(totalWeight)
-	current := 0
-	var selected TileType
-	for _, p := range tile.Possibilities {
-		current += typeWeights[p]
-		if pick < current {
-			selected = p
-			break
-		}
-	}
-
-	tile.Type = selected
-	tile.Collapsed = true
-	tile.Possibilities = []TileType{selected}
-
-	w.Propagate(x, y)
-	return false
+		w.plantBiomes(s)
+	}))
+	return w
 }
 
-func (w *WFC) Propagate(x, y int) {
-	rules := map[TileType][]TileType{
-		Water:    {Water, Land},
-		Land:     {Water, Land, Forest, Mountain},
-		Forest:   {Forest, Land},
-		Mountain: {Mountain, Land, Lava},
-		Lava:     {Lava, Mountain},
+// landRules runs the tileset's neighbor rules, with seed as the seeding
+// hook.
+func landRules(ts *Tileset, seed func(s *solver.Solver[TileType])) solver.Rules[TileType] {
+	return solver.Rules[TileType]{
+		Tiles:         ts.Types(),
+		Weight:        func(t TileType) int { return ts.Def(t).Weight },
+		Allows:        func(a, b TileType, _ int) bool { return ts.Allows(a, b) },
+		NeighborBonus: neighborBonus,
+		Seed:          seed,
 	}
+}
 
-	stack := [][2]int{{x, y}}
-	seen := make(map[[2]int]bool)
-
-	for len(stack) > 0 {
-		curr := stack[0]
-		stack = stack[1:]
-		cx, cy := curr[0], curr[1]
-
-		neighbors := [][2]int{
-			{cx, cy - 1}, {cx, cy + 1}, {cx - 1, cy}, {cx + 1, cy},
+// plantBiomes scatters each tile's seed count across the map to ensure
+// diversity. With NoiseSeeding the climate noise shapes the map first,
+// unless the tileset has no climates, and each seed looks for a cell
+// inside its tile's climate.
+func (w *WFC) plantBiomes(s *solver.Solver[TileType]) {
+	climate := w.Seeding == NoiseSeeding && w.restrictClimate(s)
+	rng := s.Rand()
+	for _, b := range w.Tileset.Types() {
+		def := w.Tileset.Def(b)
+		if def.SeedMax == 0 {
+			continue
 		}
-
-		for _, n := range neighbors {
-			nx, ny := n[0], n[1]
-			if nx < 0 || nx >= w.Width || ny < 0 || ny >= w.Height {
-				continue
-			}
-
-			if w.Grid[ny][nx].Collapsed {
-				continue
-			}
-
-			possibleForNeighbor := make(map[TileType]bool)
-			for _, p := range w.Grid[cy][cx].Possibilities {
-				for _, allowed := range rules[p] {
-					possibleForNeighbor[allowed] = true
-				}
-			}
-
-			newPossibilities := []TileType{}
-			changed := false
-			for _, p := range w.Grid[ny][nx].Possibilities {
-				if possibleForNeighbor[p] {
-					newPossibilities = append(newPossibilities, p)
-				} else {
-					changed = true
-				}
-			}
-
-			if changed {
-				w.Grid[ny][nx].Possibilities = newPossibilities
-				if !seen[[2]int{nx, ny}] {
-					stack = append(stack, [2]int{nx, ny})
-					seen[[2]int{nx, ny}] = true
-				}
+		numSeeds := def.SeedMin + rng.Intn(def.SeedMax-def.SeedMin+1)
+		for i := 0; i < numSeeds; i++ {
+			sx, sy := rng.Intn(s.Width), rng.Intn(s.Height)
+			for try := 0; climate && try < climateTries && !slices.Contains(s.Grid[sy][sx].Possibilities, b); try++ {
+				sx, sy = rng.Intn(s.Width), rng.Intn(s.Height)
 			}
+			s.Pin(sx, sy, b)
 		}
 	}
 }
-
-func (w *WFC) Step() bool {
-	return w.Collapse()
-}
@@ -0,0 +1,314 @@
+package wfc
+
+import (
+	"atlas.games/internal/mapio"
+	"atlas.games/internal/solver"
+)
+
+// ChunkSize is the width and height of a world chunk in tiles.
+const ChunkSize = 32
+
+// referenceArea is the map size the tilesets' seed counts are tuned for,
+// the Land Creator's 200x60. Chunks plant proportionally fewer seeds.
+const referenceArea = 200 * 60
+
+// chunkCoord addresses a chunk; chunk (cx, cy) covers tiles
+// [cx*ChunkSize, (cx+1)*ChunkSize) horizontally and likewise vertically.
+type chunkCoord struct{ x, y int }
+
+// World is an unbounded land map generated one chunk at a time. Every
+// chunk is a function of the world seed and its coordinates alone, so the
+// same seed gives the same world whichever way it is explored.
+//
+// Chunks agree along their seams because the seams are solved first, each
+// from its own seed: a 2x2 post at every chunk corner, then a two-cell-wide
+// strip along every chunk edge with its ends pinned to the posts. A chunk
+// pins its outer ring to the strips around it and solves the inside. If a
+// piece's pins leave it no solution it is solved again without them, and
+// the border cells that then fail to match their neighbor are counted in
+// Seams. A piece the tileset cannot be solved for at all is filled anyway
+// and counted in Seams whole, so exploring never stalls.
+type World struct {
+	Seed    int64
+	Tileset *Tileset
+	Seams   int // Cells that break the tileset's rules, see World
+
+	chunks map[chunkCoord][][]TileType
+	// Seam pieces by coordinate, see post, hstrip and vstrip
+	posts, hstrips, vstrips map[chunkCoord][][]TileType
+}
+
+// NewWorld starts an empty world. A nil tileset selects the default.
+func NewWorld(seed int64, ts *Tileset) *World {
+	if ts == nil {
+		ts = DefaultTileset()
+	}
+	return &World{
+		Seed:    seed,
+		Tileset: ts,
+		chunks:  map[chunkCoord][][]TileType{},
+		posts:   map[chunkCoord][][]TileType{},
+		hstrips: map[chunkCoord][][]TileType{},
+		vstrips: map[chunkCoord][][]TileType{},
+	}
+}
+
+// Chunks is the number of chunks generated so far.
+func (w *World) Chunks() int {
+	return len(w.chunks)
+}
+
+// floorDiv divides rounding toward negative infinity, so tile -1 lands in
+// chunk -1 rather than chunk 0.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
+
+// TileAt returns the tile at world position (x, y), generating its chunk
+// if needed.
+func (w *World) TileAt(x, y int) TileType {
+	cx, cy := floorDiv(x, ChunkSize), floorDiv(y, ChunkSize)
+	return w.Chunk(cx, cy)[y-cy*ChunkSize][x-cx*ChunkSize]
+}
+
+// Ensure generates every chunk overlapping the width x height rectangle at
+// (x, y), in row order.
+func (w *World) Ensure(x, y, width, height int) {
+	for cy := floorDiv(y, ChunkSize); cy <= floorDiv(y+height-1, ChunkSize); cy++ {
+		for cx := floorDiv(x, ChunkSize); cx <= floorDiv(x+width-1, ChunkSize); cx++ {
+			w.Chunk(cx, cy)
+		}
+	}
+}
+
+// chunkSeed mixes the world seed with chunk coordinates (splitmix64), so
+// nearby chunks get unrelated random streams.
+func chunkSeed(seed int64, cx, cy int) int64 {
+	z := uint64(seed) + uint64(int64(cx))*0x9e3779b97f4a7c15 + uint64(int64(cy))*0xc2b2ae3d27d4eb4f
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+	return int64(z ^ (z >> 31))
+}
+
+// Salts that give the seam pieces random streams apart from the chunks'.
+const (
+	postSalt   = 0x5eed_0001
+	hstripSalt = 0x5eed_0002
+	vstripSalt = 0x5eed_0003
+)
+
+// pieceRestarts is how many times a seam piece or chunk may start over,
+// first with its pins and then without them, before it is given up on.
+const pieceRestarts = 8
+
+// settle steps s until it is done or has started over more than
+// pieceRestarts times, and reports whether it finished.
+func settle(s *solver.Solver[TileType]) bool {
+	for !s.Step() {
+		if s.Restarts > pieceRestarts {
+			return false
+		}
+	}
+	return true
+}
+
+// solvePiece solves a width x height piece of the world from seed. pin
+// fixes the cells it shares with pieces solved before it, and plant, if
+// set, adds biome seeds. When the pins leave no solution within
+// pieceRestarts, the piece is solved again with only plant. A tileset
+// whose rules cannot be met even then gets the piece filled with what the
+// last attempt left, and every cell of it is counted in Seams.
+func (w *World) solvePiece(width, height int, seed int64, pin, plant func(s *solver.Solver[TileType])) [][]TileType {
+	hook := func(pinned bool) func(s *solver.Solver[TileType]) {
+		return func(s *solver.Solver[TileType]) {
+			if pinned {
+				pin(s)
+			}
+			if plant != nil {
+				plant(s)
+			}
+		}
+	}
+	s := solver.New(width, height, seed, nil, landRules(w.Tileset, hook(true)))
+	solved := settle(s)
+	if !solved {
+		s = solver.New(width, height, seed, nil, landRules(w.Tileset, hook(false)))
+		solved = settle(s)
+	}
+	if !solved {
+		w.Seams += width * height
+	}
+	fill := w.Tileset.Types()[0]
+	tiles := make([][]TileType, height)
+	for y := range tiles {
+		tiles[y] = make([]TileType, width)
+		for x := range tiles[y] {
+			switch tile := s.Grid[y][x]; {
+			case tile.Collapsed:
+				tiles[y][x] = tile.Type
+			case len(tile.Possibilities) > 0:
+				tiles[y][x] = tile.Possibilities[0]
+			default:
+				tiles[y][x] = fill
+			}
+		}
+	}
+	return tiles
+}
+
+// post is the 2x2 block of tiles around the corner shared by chunks
+// (px-1, py-1) and (px, py), at world tiles px*ChunkSize-1 and px*ChunkSize
+// across and likewise down.
+func (w *World) post(px, py int) [][]TileType {
+	key := chunkCoord{px, py}
+	if p, ok := w.posts[key]; ok {
+		return p
+	}
+	p := w.solvePiece(2, 2, chunkSeed(w.Seed^postSalt, px, py), func(*solver.Solver[TileType]) {}, nil)
+	w.posts[key] = p
+	return p
+}
+
+// hstrip is the seam between chunk (sx, sy-1) and chunk (sx, sy) below it:
+// the bottom row of the one and the top row of the other, indexed
+// [row][x]. Its end columns belong to the posts at either end.
+func (w *World) hstrip(sx, sy int) [][]TileType {
+	key := chunkCoord{sx, sy}
+	if h, ok := w.hstrips[key]; ok {
+		return h
+	}
+	left, right := w.post(sx, sy), w.post(sx+1, sy)
+	h := w.solvePiece(ChunkSize, 2, chunkSeed(w.Seed^hstripSalt, sx, sy), func(s *solver.Solver[TileType]) {
+		for row := 0; row < 2; row++ {
+			s.Pin(0, row, left[row][1])
+			s.Pin(ChunkSize-1, row, right[row][0])
+		}
+	}, nil)
+	w.hstrips[key] = h
+	return h
+}
+
+// vstrip is the seam between chunk (sx-1, sy) and chunk (sx, sy) right of
+// it: the right column of the one and the left column of the other,
+// indexed [y][column]. Its end rows belong to the posts at either end.
+func (w *World) vstrip(sx, sy int) [][]TileType {
+	key := chunkCoord{sx, sy}
+	if v, ok := w.vstrips[key]; ok {
+		return v
+	}
+	top, bottom := w.post(sx, sy), w.post(sx, sy+1)
+	v := w.solvePiece(2, ChunkSize, chunkSeed(w.Seed^vstripSalt, sx, sy), func(s *solver.Solver[TileType]) {
+		for col := 0; col < 2; col++ {
+			s.Pin(col, 0, top[1][col])
+			s.Pin(col, ChunkSize-1, bottom[0][col])
+		}
+	}, nil)
+	w.vstrips[key] = v
+	return v
+}
+
+// Chunk returns the tiles of chunk (cx, cy), indexed [y][x], generating it
+// if needed.
+func (w *World) Chunk(cx, cy int) [][]TileType {
+	key := chunkCoord{cx, cy}
+	if c, ok := w.chunks[key]; ok {
+		return c
+	}
+
+	top, bottom := w.hstrip(cx, cy), w.hstrip(cx, cy+1)
+	left, right := w.vstrip(cx, cy), w.vstrip(cx+1, cy)
+	last := ChunkSize - 1
+	tiles := w.solvePiece(ChunkSize, ChunkSize, chunkSeed(w.Seed, cx, cy), func(s *solver.Solver[TileType]) {
+		for i := 0; i < ChunkSize; i++ {
+			s.Pin(i, 0, top[1][i])
+			s.Pin(i, last, bottom[0][i])
+			s.Pin(0, i, left[i][1])
+			s.Pin(last, i, right[i][0])
+		}
+	}, w.plantChunk)
+	w.chunks[key] = tiles
+	w.Seams += w.brokenSeams(cx, cy)
+	return tiles
+}
+
+// border walks the cells of a chunk that touch neighbor chunk d, calling fn
+// with each cell and the neighbor's cell across the seam.
+func border(dir int, fn func(x, y, nx, ny int)) {
+	last := ChunkSize - 1
+	for i := 0; i < ChunkSize; i++ {
+		switch dir {
+		case solver.Up:
+			fn(i, 0, i, last)
+		case solver.Right:
+			fn(last, i, 0, i)
+		case solver.Down:
+			fn(i, last, i, 0)
+		case solver.Left:
+			fn(0, i, last, i)
+		}
+	}
+}
+
+// brokenSeams counts border cells of chunk (cx, cy) that do not fit the
+// neighbor across the seam.
+func (w *World) brokenSeams(cx, cy int) int {
+	tiles := w.chunks[chunkCoord{cx, cy}]
+	broken := 0
+	for d, off := range dirOffsets {
+		next, ok := w.chunks[chunkCoord{cx + off[0], cy + off[1]}]
+		if !ok {
+			continue
+		}
+		border(d, func(x, y, nx, ny int) {
+			if !w.Tileset.Allows(tiles[y][x], next[ny][nx]) {
+				broken++
+			}
+		})
+	}
+	return broken
+}
+
+// plantChunk plants biome seeds like plantBiomes, scaled down to the
+// chunk's share of a full map so biomes keep their usual size.
+func (w *World) plantChunk(s *solver.Solver[TileType]) {
+	rng := s.Rand()
+	for _, b := range w.Tileset.Types() {
+		def := w.Tileset.Def(b)
+		if def.SeedMax == 0 {
+			continue
+		}
+		numSeeds := def.SeedMin + rng.Intn(def.SeedMax-def.SeedMin+1)
+		for i := 0; i < numSeeds; i++ {
+			if rng.Intn(referenceArea) >= ChunkSize*ChunkSize {
+				continue
+			}
+			s.Pin(rng.Intn(ChunkSize), rng.Intn(ChunkSize), b)
+		}
+	}
+}
+
+// Map snapshots the width x height window at (x, y) for the writers in
+// mapio, generating chunks as needed. The legend lists the whole tileset.
+func (w *World) Map(x, y, width, height int) *mapio.Map {
+	m := &mapio.Map{
+		Kind:   "land",
+		Width:  width,
+		Height: height,
+		Seed:   w.Seed,
+		Cells:  make([][]mapio.Cell, height),
+	}
+	for _, t := range w.Tileset.Types() {
+		m.Legend = append(m.Legend, w.Tileset.Cell(t))
+	}
+	for row := 0; row < height; row++ {
+		m.Cells[row] = make([]mapio.Cell, width)
+		for col := 0; col < width; col++ {
+			m.Cells[row][col] = w.Tileset.Cell(w.TileAt(x+col, y+row))
+		}
+	}
+	return m
+}
@@ -0,0 +1,51 @@
+package wfc
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestWorldOrderIndependent checks that a chunk is the same whichever way
+// the world around it was explored.
+func TestWorldOrderIndependent(t *testing.T) {
+	orders := map[string][][2]int{
+		"rows":    {{-1, -1}, {0, -1}, {1, -1}, {-1, 0}, {0, 0}, {1, 0}, {-1, 1}, {0, 1}, {1, 1}},
+		"reverse": {{1, 1}, {0, 1}, {-1, 1}, {1, 0}, {0, 0}, {-1, 0}, {1, -1}, {0, -1}, {-1, -1}},
+		"centre":  {{0, 0}, {1, 1}, {-1, -1}, {1, -1}, {-1, 1}, {0, 1}, {0, -1}, {1, 0}, {-1, 0}},
+	}
+	var first *World
+	for name, order := range orders {
+		w := NewWorld(11, nil)
+		for _, c := range order {
+			w.Chunk(c[0], c[1])
+		}
+		if first == nil {
+			first = w
+			continue
+		}
+		for _, c := range order {
+			a, b := first.Chunk(c[0], c[1]), w.Chunk(c[0], c[1])
+			if !slices.EqualFunc(a, b, slices.Equal) {
+				t.Errorf("%s: chunk %d,%d differs", name, c[0], c[1])
+			}
+		}
+	}
+}
+
+// TestWorldUnsatisfiable checks that a tileset whose rules can never be
+// met still yields chunks, counted in Seams, instead of hanging.
+func TestWorldUnsatisfiable(t *testing.T) {
+	ts, err := ParseTileset(strings.NewReader("(name) lonely\n(tiles)\n  > (tile)\n    (id) a\n    (glyph) a\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := NewWorld(1, ts)
+	c := w.Chunk(0, 0)
+	if len(c) != ChunkSize || len(c[0]) != ChunkSize {
+		t.Fatalf("chunk is %dx%d", len(c[0]), len(c))
+	}
+	if w.Seams == 0 {
+		t.Error("no seams counted")
+	}
+}
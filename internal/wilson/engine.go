@@ -55,10 +55,15 @@ type GameState struct {
 	InvincibleTimer int
 	DoubleScoreTimer int
 	JumpDisabled     bool
+
+	Seed int64
+	rng  *rand.Rand
 }
 
-func NewGameState() *GameState {
+func NewGameState(seed int64) *GameState {
 	return &GameState{
+		Seed:        seed,
+		rng:         rand.New(rand.NewSource(seed)),
 		Started:     false,
 		Paused:      false,
 		ChickenLane: 1,
@@ -119,13 +124,13 @@ func (s *GameState) Update() {
 	if s.FrameCount % spawnInterval == 0 {
 		// Occasionally spawn in two lanes at once to force movement
 		numToSpawn := 1
-		if s.Speed > 2.0 && rand.Float64() < 0.25 { numToSpawn = 2 }
-		if s.Speed > 3.5 && rand.Float64() < 0.45 { numToSpawn = 2 }
+		if s.Speed > 2.0 && s.rng.Float64() < 0.25 { numToSpawn = 2 }
+		if s.Speed > 3.5 && s.rng.Float64() < 0.45 { numToSpawn = 2 }
 
 		spawnedLanes := make(map[int]bool)
 
 		for i := 0; i < numToSpawn; i++ {
-			lane := rand.Intn(Lanes)
+			lane := s.rng.Intn(Lanes)
 			if spawnedLanes[lane] { continue }
 			
 			// Tighter buffer for denser action
@@ -142,7 +147,7 @@ func (s *GameState) Update() {
 
 			if laneClear {
 				spawnedLanes[lane] = true
-				roll := rand.Float64()
+				roll := s.rng.Float64()
 				
 				// Higher probability for enemies/cars
 				carThreshold := 0.35 - (s.Speed * 0.04)
@@ -156,10 +161,10 @@ func (s *GameState) Update() {
 				} else if roll < 0.60 {
 					s.Objects = append(s.Objects, GameObject{Type: TypeCar, Lane: lane, X: float64(GameWidth), Speed: 0.6 + (s.Speed * 0.12)})
 				} else if roll < enemyThreshold {
-					s.Objects = append(s.Objects, GameObject{Type: TypeEnemy, Lane: lane, X: float64(GameWidth), Speed: rand.Float64() * (0.4 + s.Speed*0.12)})
+					s.Objects = append(s.Objects, GameObject{Type: TypeEnemy, Lane: lane, X: float64(GameWidth), Speed: s.rng.Float64() * (0.4 + s.Speed*0.12)})
 				} else {
 					// Items
-					itemRoll := rand.Float64()
+					itemRoll := s.rng.Float64()
 					if itemRoll < 0.15 {
 						if !s.HasGun {
 							s.Objects = append(s.Objects, GameObject{Type: TypeGun, Lane: lane, X: float64(GameWidth), Speed: 0})
@@ -181,7 +186,7 @@ func (s *GameState) Update() {
 	}
 
 	// Trees
-	if s.FrameCount%40 == 0 && rand.Float64() < 0.3 {
+	if s.FrameCount%40 == 0 && s.rng.Float64() < 0.3 {
 		s.Background = append(s.Background, GameObject{Type: TypeTree, X: float64(GameWidth)})
 	}
 
@@ -7,6 +7,7 @@ import (
 
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"atlas.games/internal/registry"
 )
 
 var (
@@ -35,9 +36,20 @@ type Model struct {
 	State *GameState
 }
 
-func NewModel() Model {
+func init() {
+	registry.Register(registry.Game{
+		ID:          "wilson",
+		Title:       "Wilson's Revenge",
+		Description: "High-speed lane runner. Dodge cars, jump barricades and blast enemies.",
+		Category:    registry.Arcade,
+		Order:       1,
+		New:         func(seed int64) tea.Model { return NewModel(seed) },
+	})
+}
+
+func NewModel(seed int64) Model {
 	return Model{
-		State: NewGameState(),
+		State: NewGameState(seed),
 	}
 }
 
@@ -53,7 +65,7 @@ func (m Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m, tea.Quit
 		case "r":
 			if m.State.GameOver {
-				m.State = NewGameState()
+				m.State = NewGameState(time.Now().UnixNano())
 				m.State.Started = true
 				return m, tick()
 			}
@@ -235,11 +247,12 @@ func (m Model) View() string {
 		status += " [JUMP DISABLED]"
 	}
 
-	sb.WriteString(fmt.Sprintf(" %s | Score: %d | Ammo: %d | Speed: %.2f%s\n\n", 
+	sb.WriteString(fmt.Sprintf(" %s | Score: %d | Ammo: %d | Speed: %.2f | Seed: %d%s\n\n", 
 		titleStyle.Render("WILSON'S REVENGE"), 
 		m.State.Score, 
 		m.State.Ammo, 
 		m.State.Speed,
+		m.State.Seed,
 		status))
 
 	for _, line := range buffer {
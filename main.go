@@ -1,28 +1,56 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
+	"time"
 
 	tea "github.com/charmbracelet/bubbletea"
+	"atlas.games/internal/gen"
 	"atlas.games/internal/menu"
-	"atlas.games/internal/wilson"
-	"atlas.games/internal/wfc"
-	"atlas.games/internal/city"
-	"atlas.games/internal/colony"
-	"atlas.games/internal/warlord"
-	"atlas.games/internal/defense"
-	"atlas.games/internal/breach"
+	"atlas.games/internal/registry"
+
+	// Games register themselves with the launcher on import.
+	_ "atlas.games/internal/wilson"
+	_ "atlas.games/internal/wfc"
+	_ "atlas.games/internal/city"
+	_ "atlas.games/internal/colony"
+	_ "atlas.games/internal/warlord"
+	_ "atlas.games/internal/defense"
+	_ "atlas.games/internal/breach"
 )
 
 var Version = "dev"
 
 func main() {
-	if len(os.Args) > 1 && (os.Args[1] == "-v" || os.Args[1] == "--version") {
+	if len(os.Args) > 1 && os.Args[1] == "gen" {
+		if err := gen.Run(os.Args[2:], os.Stdout, os.Stderr); err != nil {
+			fmt.Fprintf(os.Stderr, "gen: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	var showVersion bool
+	var seed int64
+	flag.BoolVar(&showVersion, "v", false, "print the version and exit")
+	flag.BoolVar(&showVersion, "version", false, "print the version and exit")
+	flag.Int64Var(&seed, "seed", 0, "seed for every game's random source (default: a fresh seed per launch)")
+	flag.Parse()
+
+	if showVersion {
 		fmt.Printf("atlas.games v%s\n", Version)
 		return
 	}
 
+	seedSet := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == "seed" {
+			seedSet = true
+		}
+	})
+
 	for {
 		// 1. Run Menu
 		m := menu.NewModel()
@@ -36,33 +64,21 @@ func main() {
 		choice := res.(menu.Model).Choice()
 
 		// 2. Launch Game
-		var gameModel tea.Model
-		switch choice {
-		case "Wilson's Revenge":
-			gameModel = wilson.NewModel()
-		case "WFC Land Creator":
-			gameModel = wfc.NewModel()
-		case "WFC City Generator":
-			gameModel = city.NewModel()
-		case "Tactical Colony":
-			gameModel = colony.NewModel()
-		case "Atlas Warlord":
-			gameModel = warlord.NewModel()
-		case "Atlas Defense":
-			gameModel = defense.NewModel()
-		case "Atlas Breach":
-			gameModel = breach.NewModel()
-		case "Exit", "":
+		game, ok := registry.Lookup(choice)
+		if !ok {
 			fmt.Println("Goodbye, operator.")
 			return
 		}
 
-		if gameModel != nil {
-			gp := tea.NewProgram(gameModel, tea.WithAltScreen())
-			if _, err := gp.Run(); err != nil {
-				fmt.Fprintf(os.Stderr, "Error running game: %v\n", err)
-				os.Exit(1)
-			}
+		gameSeed := seed
+		if !seedSet {
+			gameSeed = time.Now().UnixNano()
+		}
+
+		gp := tea.NewProgram(game.New(gameSeed), tea.WithAltScreen())
+		if _, err := gp.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error running game: %v\n", err)
+			os.Exit(1)
 		}
 	}
 }